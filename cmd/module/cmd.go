@@ -6,6 +6,7 @@ import (
 	"go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/module"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
 	"go.viam.com/rdk/services/vision"
 
 	"github.com/erh/vmodutils/touch"
@@ -23,6 +24,9 @@ func main() {
 		resource.APIModel{vision.API, touch.ClusterModel},
 		resource.APIModel{camera.API, touch.LookAtCameraModel},
 		resource.APIModel{toggleswitch.API, touch.MultiArmPositionSwitchModel},
+		resource.APIModel{camera.API, touch.VoxelCameraModel},
+		resource.APIModel{camera.API, touch.RemovePlaneCameraModel},
+		resource.APIModel{generic.API, touch.HandEyeCalibrationModel},
 	)
 
 }
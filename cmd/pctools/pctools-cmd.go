@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image/color"
@@ -41,10 +42,27 @@ func realMain() error {
 	colorRed := flag.Int("color-red", 255, "")
 	colorGreen := flag.Int("color-green", 255, "")
 	colorBlue := flag.Int("color-blue", 255, "")
+	colorSpace := flag.String("color-space", "rgb", "rgb, hsv, or lab")
+	colorExclude := flag.Bool("color-exclude", false, "drop points that match the color instead of keeping them")
+	hueRange := flag.Float64("hue-range", 0, "for hsv, degrees")
+	saturationRange := flag.Float64("saturation-range", 0, "for hsv, 0-1")
+	valueRange := flag.Float64("value-range", 0, "for hsv, 0-1")
+
+	projection := flag.String("projection", "top", "for image: top, front, or side")
+	pixelsPerMM := flag.Float64("pixels-per-mm", 1, "for image")
+	width := flag.Int("width", 0, "for image, overrides pixels-per-mm")
+	depthColormap := flag.Bool("depth-colormap", false, "for image, color by depth")
+	fillHoles := flag.Int("fill-holes", 0, "for image, passes of hole filling")
 
 	maxDistance := flag.Float64("max-distance", 30, "")
 	minPointsPerSegment := flag.Int("min-points-per-segment", 20, "")
 	minPointsPerCluster := flag.Int("min-points-per-cluster", 100, "")
+	clusterAlgorithm := flag.String("cluster-algorithm", "grid", "grid or dbscan")
+
+	boardCols := flag.Int("board-cols", 0, "for hand-eye, inner corners across the checkerboard")
+	boardRows := flag.Int("board-rows", 0, "for hand-eye, inner corners down the checkerboard")
+	squareMM := flag.Float64("square-mm", 0, "for hand-eye, checkerboard square size")
+	parent := flag.String("parent", "arm", "for hand-eye, parent of the camera frame")
 
 	flag.Parse()
 
@@ -153,7 +171,16 @@ func realMain() error {
 		if err != nil {
 			return err
 		}
-		img := touch.PCToImage(in)
+		img, err := touch.PCToImageWithOptions(in, &touch.RenderOptions{
+			Projection:    *projection,
+			PixelsPerMM:   *pixelsPerMM,
+			Width:         *width,
+			DepthColormap: *depthColormap,
+			FillHoles:     *fillHoles,
+		})
+		if err != nil {
+			return err
+		}
 		if *out == "" {
 			return fmt.Errorf("need an out")
 		}
@@ -169,9 +196,21 @@ func realMain() error {
 		min := r3.Vector{-50000, -50000, -50000}
 		max := r3.Vector{min.X * -1, min.Y * -1, min.Z * -1}
 
-		filtered := touch.PCCropWithColor(in, min, max, []touch.ColorFilter{
-			{color.RGBA{uint8(*colorRed), uint8(*colorGreen), uint8(*colorBlue), 0}, *colorDistance},
-		})
+		cf := touch.ColorFilter{
+			Color:           color.RGBA{uint8(*colorRed), uint8(*colorGreen), uint8(*colorBlue), 0},
+			Distance:        *colorDistance,
+			Space:           *colorSpace,
+			HueRange:        *hueRange,
+			SaturationRange: *saturationRange,
+			ValueRange:      *valueRange,
+			Exclude:         *colorExclude,
+		}
+		err = cf.Validate()
+		if err != nil {
+			return err
+		}
+
+		filtered := touch.PCCropWithColor(in, min, max, []touch.ColorFilter{cf})
 
 		if *out == "" {
 			return fmt.Errorf("need an out")
@@ -186,7 +225,15 @@ func realMain() error {
 			return err
 		}
 
-		clusters, err := touch.Cluster(in, *maxDistance, *minPointsPerSegment, *minPointsPerCluster)
+		var clusters []pointcloud.PointCloud
+		switch *clusterAlgorithm {
+		case "grid":
+			clusters, err = touch.Cluster(in, *maxDistance, *minPointsPerSegment, *minPointsPerCluster)
+		case "dbscan":
+			clusters, err = touch.ClusterDBSCAN(in, *maxDistance, *minPointsPerSegment, *minPointsPerCluster)
+		default:
+			return fmt.Errorf("unknown cluster-algorithm [%s]", *clusterAlgorithm)
+		}
 		if err != nil {
 			return err
 		}
@@ -318,6 +365,39 @@ func realMain() error {
 		return nil
 	}
 
+	if *cmd == "hand-eye" {
+		if *in == "" {
+			return fmt.Errorf("need an 'in' directory written by hand-eye-calibration")
+		}
+		board := touch.CheckerboardConfig{Cols: *boardCols, Rows: *boardRows, SquareMM: *squareMM}
+
+		samples, skipped, err := touch.ReadHandEyeSamples(*in, board)
+		if err != nil {
+			return err
+		}
+		for pos, err := range skipped {
+			logger.Warnf("skipped position %d: %v", pos, err)
+		}
+
+		res, err := touch.SolveHandEye(samples)
+		if err != nil {
+			return err
+		}
+		logger.Infof("used %d samples, translation error: %0.2f mm rotation error: %0.2f degrees",
+			res.Samples, res.TranslationErrorMM, res.RotationErrorDeg)
+
+		data, err := json.MarshalIndent(res.FrameConfig(*parent), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+
+		if *out != "" {
+			return os.WriteFile(*out, data, 0o644)
+		}
+		return nil
+	}
+
 	return fmt.Errorf("invalid command [%s]", *cmd)
 
 }
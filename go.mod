@@ -8,6 +8,7 @@ require (
 	go.viam.com/rdk v0.105.0
 	go.viam.com/test v1.2.4
 	go.viam.com/utils v0.4.0
+	gonum.org/v1/gonum v0.16.0
 	neilpa.me/go-stl v0.5.0
 )
 
@@ -220,7 +221,6 @@ require (
 	golang.org/x/time v0.6.0 // indirect
 	golang.org/x/tools v0.38.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
-	gonum.org/v1/gonum v0.16.0 // indirect
 	gonum.org/v1/plot v0.15.2 // indirect
 	google.golang.org/api v0.196.0 // indirect
 	google.golang.org/genproto v0.0.0-20240903143218-8af14fe29dc1 // indirect
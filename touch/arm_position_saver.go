@@ -3,13 +3,16 @@ package touch
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/golang/geo/r3"
 
 	"go.viam.com/rdk/components/arm"
 	toggleswitch "go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/services/motion"
@@ -39,6 +42,16 @@ type ArmPositionSaverConfig struct {
 	Orientation    spatialmath.OrientationVectorDegrees `json:"orientation,omitzero"`
 	VisionServices []string                             `json:"vision_services,omitempty"`
 	Extra          map[string]interface{}               `json:"extra,omitempty"`
+	// Async makes SetPosition return once the arm starts moving, the status command says when it's done
+	Async bool `json:"async,omitempty"`
+	// how close the arm has to be to the saved position for GetPosition to say it's there, all default to 1
+	JointToleranceDegs       float64 `json:"joint_tolerance_degs,omitempty"`
+	ToleranceMM              float64 `json:"tolerance_mm,omitempty"`
+	OrientationToleranceDegs float64 `json:"orientation_tolerance_degs,omitempty"`
+}
+
+func (c *ArmPositionSaverConfig) tolerances() armTolerances {
+	return newArmTolerances(c.JointToleranceDegs, c.ToleranceMM, c.OrientationToleranceDegs)
 }
 
 func (c *ArmPositionSaverConfig) Validate(path string) ([]string, []string, error) {
@@ -110,7 +123,6 @@ func newArmPositionSaver(ctx context.Context, deps resource.Dependencies, config
 
 type ArmPositionSaver struct {
 	resource.AlwaysRebuild
-	resource.TriviallyCloseable
 
 	name   resource.Name
 	cfg    *ArmPositionSaverConfig
@@ -120,6 +132,11 @@ type ArmPositionSaver struct {
 	motion         motion.Service
 	visionServices []vision.Service
 	fsSvc          framesystem.Service
+
+	// 'mu' protects 'run' and 'target', 'run' is what's saving or moving now, or did last
+	mu     sync.Mutex
+	run    *armRun
+	target uint32
 }
 
 func (aps *ArmPositionSaver) Name() resource.Name {
@@ -140,38 +157,122 @@ func (aps *ArmPositionSaver) DoCommand(ctx context.Context, cmd map[string]inter
 			"as_json":     string(jsonData),
 		}, nil
 	}
+
+	if cmd["status"] == true {
+		return aps.statusMap(), nil
+	}
+
+	if cmd["stop"] == true {
+		aps.mu.Lock()
+		run := aps.run
+		aps.mu.Unlock()
+
+		err := stopArmRun(ctx, aps.arm, run)
+		if err != nil {
+			return nil, err
+		}
+		return aps.statusMap(), nil
+	}
+
 	return nil, fmt.Errorf("unknown command %v", cmd)
 }
 
+func (aps *ArmPositionSaver) statusMap() map[string]interface{} {
+	aps.mu.Lock()
+	defer aps.mu.Unlock()
+
+	m := map[string]interface{}{"executing": false}
+	if aps.run == nil {
+		return m
+	}
+	m["run"] = aps.run.status()
+	if aps.run.running() {
+		m["executing"] = true
+		m["target"] = aps.target
+	}
+	return m
+}
+
 func (aps *ArmPositionSaver) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
-	if position == 0 {
+	var do func(ctx context.Context) error
+	switch position {
+	case 0:
 		return nil
+	case 1:
+		do = aps.saveCurrentPosition
+	case 2:
+		do = aps.goToSavePosition
+	default:
+		return fmt.Errorf("bad position: %d", position)
 	}
 
-	if position == 1 {
-		return aps.saveCurrentPosition(ctx)
+	aps.mu.Lock()
+	if aps.run != nil && aps.run.running() {
+		aps.mu.Unlock()
+		return errors.New("switch is currently executing")
 	}
-
-	if position == 2 {
-		return aps.goToSavePosition(ctx)
+	run := startArmRun(ctx, 1, false, func(ctx context.Context, step int) error {
+		return do(ctx)
+	}, aps.logger)
+	aps.run = run
+	aps.target = position
+	aps.mu.Unlock()
+
+	if !shouldWait(aps.cfg.Async, extra) {
+		return nil
 	}
-
-	return fmt.Errorf("bad position: %d", position)
+	return run.wait(ctx)
 }
 
+// GetPosition is 2, "go to", if the arm is at the saved position, and 3, "unknown", if it isn't.
 func (aps *ArmPositionSaver) GetPosition(ctx context.Context, extra map[string]interface{}) (uint32, error) {
-	return 0, nil
+	tol := aps.cfg.tolerances()
+
+	if len(aps.cfg.Joints) > 0 {
+		joints, err := aps.arm.JointPositions(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		if tol.jointsMatch(joints, aps.cfg.Joints) {
+			return 2, nil
+		}
+		return 3, nil
+	}
+
+	if aps.motion != nil {
+		current, err := aps.fsSvc.GetPose(ctx, aps.cfg.Arm, referenceframe.World, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		if tol.poseMatches(current.Pose(), spatialmath.NewPose(aps.cfg.Point, &aps.cfg.Orientation)) {
+			return 2, nil
+		}
+	}
+
+	return 3, nil
 }
 
 func (aps *ArmPositionSaver) GetNumberOfPositions(ctx context.Context, extra map[string]interface{}) (uint32, []string, error) {
-	return 3, []string{"idle", "update config", "go to"}, nil
+	return 4, []string{"idle", "update config", "go to", ArmPositionUnknownLabel}, nil
 }
 
-func (aps *ArmPositionSaver) saveCurrentPosition(ctx context.Context) error {
-	newConfig := utils.AttributeMap{
-		"arm":    aps.cfg.Arm,
-		"motion": aps.cfg.Motion,
+func (aps *ArmPositionSaver) Close(ctx context.Context) error {
+	aps.mu.Lock()
+	run := aps.run
+	aps.mu.Unlock()
+
+	if run != nil {
+		run.stop()
 	}
+	return nil
+}
+
+func (aps *ArmPositionSaver) saveCurrentPosition(ctx context.Context) error {
+	// keep everything else, like the tolerances, but only the new position
+	newConfig := *aps.cfg
+	newConfig.Joints = nil
+	newConfig.Point = r3.Vector{}
+	newConfig.Orientation = spatialmath.OrientationVectorDegrees{}
 
 	if aps.cfg.Motion == "" {
 		inputs, err := aps.arm.JointPositions(ctx, nil)
@@ -179,30 +280,41 @@ func (aps *ArmPositionSaver) saveCurrentPosition(ctx context.Context) error {
 			return err
 		}
 
-		newConfig["joints"] = inputs
+		newConfig.Joints = inputs
 	} else {
 		p, err := aps.fsSvc.GetPose(ctx, aps.cfg.Arm, "world", nil, nil)
 		if err != nil {
 			return err
 		}
-		newConfig["point"] = p.Pose().Point()
-		newConfig["orientation"] = p.Pose().Orientation().OrientationVectorDegrees()
+		newConfig.Point = p.Pose().Point()
+		newConfig.Orientation = *p.Pose().Orientation().OrientationVectorDegrees()
+	}
+
+	data, err := json.Marshal(&newConfig)
+	if err != nil {
+		return err
+	}
+	attrs := utils.AttributeMap{}
+	err = json.Unmarshal(data, &attrs)
+	if err != nil {
+		return err
 	}
 
-	return vmodutils.UpdateComponentCloudAttributesFromModuleEnv(ctx, aps.name, newConfig, aps.logger)
+	return vmodutils.UpdateComponentCloudAttributesFromModuleEnv(ctx, aps.name, attrs, aps.logger)
 }
 
 func (aps *ArmPositionSaver) goToSavePosition(ctx context.Context) error {
 	if len(aps.cfg.Joints) > 0 {
 		if aps.motion != nil {
-			goToPositionUsingJointToJointMotion(ctx, aps.cfg.Joints, aps.arm.Name().Name, aps.motion, aps.visionServices, aps.cfg.Extra, aps.logger)
+			return goToPositionUsingJointToJointMotion(ctx, aps.cfg.Joints, aps.arm.Name().Name, aps.motion, aps.visionServices, aps.cfg.Extra, aps.logger)
 		} else {
 			return goToPositionUsingMoveToJointPositions(ctx, aps.cfg.Joints, aps.arm, aps.cfg.Extra, aps.logger)
 		}
 	}
 
 	if aps.motion != nil {
-		return goToPositionUsingCartesianMotion(ctx, aps.cfg.Point, aps.cfg.Orientation, aps.motion, aps.visionServices, aps.fsSvc, aps.arm.Name().Name, aps.cfg.Extra, aps.logger)
+		goal := referenceframe.NewPoseInFrame(referenceframe.World, spatialmath.NewPose(aps.cfg.Point, &aps.cfg.Orientation))
+		return goToPositionUsingCartesianMotion(ctx, goal, aps.motion, aps.visionServices, aps.fsSvc, aps.arm.Name().Name, aps.cfg.Extra, aps.logger)
 	}
 
 	return fmt.Errorf("need to configure where to go")
@@ -0,0 +1,113 @@
+package touch
+
+import (
+	"context"
+	"testing"
+
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils"
+)
+
+func TestArmPositionSaverBusy(t *testing.T) {
+	ctx := context.Background()
+
+	letGo := make(chan struct{})
+	fakeArm := inject.NewArm("arm")
+	fakeArm.MoveToJointPositionsFunc = func(ctx context.Context, joints []float64, extra map[string]any) error {
+		select {
+		case <-letGo:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	fakeArm.StopFunc = func(ctx context.Context, extra map[string]interface{}) error {
+		return nil
+	}
+	fakeFsSvc := inject.NewFrameSystemService(framesystem.PublicServiceName.Name)
+
+	cfg := &ArmPositionSaverConfig{Arm: "arm", Joints: []float64{1, 1, 1}, Async: true}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	s, err := newArmPositionSaver(ctx, resource.Dependencies{
+		fakeArm.Name():   fakeArm,
+		fakeFsSvc.Name(): fakeFsSvc,
+	}, resource.Config{
+		Name:                "saver",
+		API:                 toggleswitch.API,
+		Model:               resource.Model{Family: vmodutils.NamespaceFamily},
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	defer s.Close(ctx)
+
+	err = s.SetPosition(ctx, 2, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	// can't save or move again while it's moving
+	err = s.SetPosition(ctx, 1, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	err = s.SetPosition(ctx, 2, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	status, err := s.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status["executing"], test.ShouldBeTrue)
+	test.That(t, status["target"], test.ShouldEqual, 2)
+
+	status, err = s.DoCommand(ctx, map[string]interface{}{"stop": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status["executing"], test.ShouldBeFalse)
+
+	close(letGo)
+	err = s.SetPosition(ctx, 2, nil)
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestArmPositionSaverGetPosition(t *testing.T) {
+	ctx := context.Background()
+
+	current := []float64{1, 1, 1}
+	fakeArm := inject.NewArm("arm")
+	fakeArm.JointPositionsFunc = func(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+		return current, nil
+	}
+	fakeFsSvc := inject.NewFrameSystemService(framesystem.PublicServiceName.Name)
+
+	cfg := &ArmPositionSaverConfig{Arm: "arm", Joints: []float64{1, 1, 1}, JointToleranceDegs: 5}
+	s, err := newArmPositionSaver(ctx, resource.Dependencies{
+		fakeArm.Name():   fakeArm,
+		fakeFsSvc.Name(): fakeFsSvc,
+	}, resource.Config{
+		Name:                "saver",
+		API:                 toggleswitch.API,
+		Model:               resource.Model{Family: vmodutils.NamespaceFamily},
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	position, err := s.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, position, test.ShouldEqual, 2)
+
+	current = []float64{1, 1.05, 1}
+	position, err = s.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, position, test.ShouldEqual, 2)
+
+	current = []float64{1, 1.2, 1}
+	position, err = s.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, position, test.ShouldEqual, 3)
+	_, labels, err := s.GetNumberOfPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, labels[position], test.ShouldEqual, ArmPositionUnknownLabel)
+	test.That(t, s.SetPosition(ctx, position, nil), test.ShouldNotBeNil)
+}
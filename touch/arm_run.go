@@ -0,0 +1,180 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/logging"
+)
+
+// errArmRunStopped is what a run fails with when it's stopped before it's done.
+var errArmRunStopped = errors.New("stopped")
+
+// waitExtra is the extra for SetPosition on an async arm switch that makes it wait until the arm is there anyway.
+func waitExtra() map[string]interface{} {
+	return map[string]interface{}{"wait": true}
+}
+
+// shouldWait is whether SetPosition should wait for the arm, which it always does unless the switch is async.
+func shouldWait(async bool, extra map[string]interface{}) bool {
+	return !async || extra["wait"] == true
+}
+
+// armRun does a list of steps that move an arm in the background, so the caller can wait for it,
+// or come back later to see how it's going or stop it.
+type armRun struct {
+	steps int
+
+	// 'mu' protects everything below
+	mu        sync.Mutex
+	step      int
+	completed int
+	errors    map[string]interface{}
+	err       error
+	start     time.Time
+	end       time.Time
+
+	cancel   context.CancelFunc
+	finished chan struct{}
+}
+
+// startArmRun calls do for every step in order, stopping at the first error unless continueOnError is set.
+// The run keeps ctx's values, like the trace, but not its deadline, so it can outlive the call that started it.
+func startArmRun(
+	ctx context.Context,
+	steps int,
+	continueOnError bool,
+	do func(ctx context.Context, step int) error,
+	logger logging.Logger,
+) *armRun {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r := &armRun{
+		steps:    steps,
+		errors:   map[string]interface{}{},
+		start:    time.Now(),
+		cancel:   cancel,
+		finished: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(r.finished)
+		defer cancel()
+
+		var err error
+		for i := 0; i < steps; i++ {
+			r.mu.Lock()
+			r.step = i
+			r.mu.Unlock()
+
+			err = do(ctx, i)
+
+			r.mu.Lock()
+			r.completed++
+			if err != nil {
+				r.errors[fmt.Sprintf("%d", i)] = err.Error()
+			}
+			r.mu.Unlock()
+
+			if err == nil {
+				continue
+			}
+			logger.Warnf("step %d failed: %v", i, err)
+			if ctx.Err() != nil {
+				err = errArmRunStopped
+				break
+			}
+			if !continueOnError {
+				if steps > 1 {
+					err = fmt.Errorf("step %d failed: %w", i, err)
+				}
+				break
+			}
+			err = nil
+		}
+
+		r.mu.Lock()
+		r.err = err
+		r.end = time.Now()
+		r.mu.Unlock()
+	}()
+
+	return r
+}
+
+func (r *armRun) running() bool {
+	select {
+	case <-r.finished:
+		return false
+	default:
+		return true
+	}
+}
+
+// currentStep is the step being done now, or the last one if the run is over.
+func (r *armRun) currentStep() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.step
+}
+
+// wait waits for the run to finish and returns why it failed, if it did.
+// If ctx is done first the run is stopped.
+func (r *armRun) wait(ctx context.Context) error {
+	select {
+	case <-r.finished:
+	case <-ctx.Done():
+		r.stop()
+		return ctx.Err()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// stop cancels the run and waits for it to finish.
+func (r *armRun) stop() {
+	r.cancel()
+	<-r.finished
+}
+
+func (r *armRun) status() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stepErrors := map[string]interface{}{}
+	for k, v := range r.errors {
+		stepErrors[k] = v
+	}
+	m := map[string]interface{}{
+		"running":   r.end.IsZero(),
+		"step":      r.step,
+		"steps":     r.steps,
+		"completed": r.completed,
+		"errors":    stepErrors,
+	}
+	if r.end.IsZero() {
+		m["elapsed_seconds"] = time.Since(r.start).Seconds()
+	} else {
+		m["elapsed_seconds"] = r.end.Sub(r.start).Seconds()
+	}
+	if r.err != nil {
+		m["error"] = r.err.Error()
+	}
+	return m
+}
+
+// stopArmRun cancels run, which can be nil, stops a, and waits for run to finish.
+func stopArmRun(ctx context.Context, a arm.Arm, run *armRun) error {
+	if run != nil {
+		run.cancel()
+	}
+	err := a.Stop(ctx, nil)
+	if run != nil {
+		<-run.finished
+	}
+	return err
+}
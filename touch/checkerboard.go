@@ -0,0 +1,604 @@
+package touch
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// CheckerboardConfig describes a printed checkerboard by its inner corners, the ones where four squares meet.
+type CheckerboardConfig struct {
+	Cols     int     `json:"cols"`
+	Rows     int     `json:"rows"`
+	SquareMM float64 `json:"square_mm"`
+}
+
+func (c *CheckerboardConfig) Validate() error {
+	if c.Cols < 3 || c.Rows < 3 {
+		return fmt.Errorf("checkerboard needs at least 3 cols and rows of inner corners, got %dx%d", c.Cols, c.Rows)
+	}
+	if (c.Cols+c.Rows)%2 == 0 {
+		return fmt.Errorf("checkerboard cols + rows has to be odd so which way it's facing isn't ambiguous, got %dx%d", c.Cols, c.Rows)
+	}
+	if c.SquareMM <= 0 {
+		return fmt.Errorf("checkerboard needs a square_mm")
+	}
+	return nil
+}
+
+// points is where the corners are on the board in mm, in the order FindCheckerboard returns them.
+// The board's frame has x along the cols, y down the rows, and z into the board.
+func (c *CheckerboardConfig) points() []r3.Vector {
+	pts := make([]r3.Vector, 0, c.Cols*c.Rows)
+	for y := 0; y < c.Rows; y++ {
+		for x := 0; x < c.Cols; x++ {
+			pts = append(pts, r3.Vector{X: float64(x) * c.SquareMM, Y: float64(y) * c.SquareMM})
+		}
+	}
+	return pts
+}
+
+const (
+	// checkerBlur is the sigma (pixels) of the blur before looking for corners
+	checkerBlur = 1.5
+	// checkerMaxCandidates keeps a busy background from making grid growing slow
+	checkerMaxCandidates = 1000
+)
+
+// FindCheckerboard finds the inner corners of board in img with sub pixel accuracy. They are ordered along
+// the cols then down the rows, starting from the corner of a dark square, going so the board's z axis points
+// away from the camera.
+func FindCheckerboard(img image.Image, board CheckerboardConfig) ([]r2.Point, error) {
+	err := board.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	g := newGrayImage(img)
+	g.blur(checkerBlur)
+
+	cands := g.saddlePoints()
+	if len(cands) < board.Cols*board.Rows {
+		return nil, fmt.Errorf("only found %d corners, need %d", len(cands), board.Cols*board.Rows)
+	}
+
+	// the strongest corners are most likely on the board
+	for seed := 0; seed < len(cands) && seed < 50; seed++ {
+		grid := growCheckerGrid(cands, seed)
+		corners, ok := orderCheckerGrid(g, cands, grid, board)
+		if ok {
+			return corners, nil
+		}
+	}
+
+	return nil, fmt.Errorf("couldn't find a %dx%d checkerboard", board.Cols, board.Rows)
+}
+
+// CheckerboardPose is where the board is in the camera's frame (mm), from its corners and the camera's intrinsics.
+// Also returns the RMS reprojection error in pixels. Lens distortion is ignored.
+func CheckerboardPose(corners []r2.Point, board CheckerboardConfig, props camera.Properties) (spatialmath.Pose, float64, error) {
+	intrinsics := props.IntrinsicParams
+	if intrinsics == nil {
+		return nil, 0, fmt.Errorf("intrinsics cannot be null")
+	}
+	if len(corners) != board.Cols*board.Rows {
+		return nil, 0, fmt.Errorf("have %d corners, board has %d", len(corners), board.Cols*board.Rows)
+	}
+
+	boardPoints := board.points()
+
+	normalized := make([]r2.Point, len(corners))
+	for i, c := range corners {
+		normalized[i] = r2.Point{X: (c.X - intrinsics.Ppx) / intrinsics.Fx, Y: (c.Y - intrinsics.Ppy) / intrinsics.Fy}
+	}
+
+	rot, t, err := planarPoseFromHomography(boardPoints, normalized)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	project := func(rot mat3, t r3.Vector, p r3.Vector) r2.Point {
+		c := rot.mulVec(p).Add(t)
+		return r2.Point{X: intrinsics.Fx*c.X/c.Z + intrinsics.Ppx, Y: intrinsics.Fy*c.Y/c.Z + intrinsics.Ppy}
+	}
+
+	residuals := func(rot mat3, t r3.Vector) []float64 {
+		res := make([]float64, 0, 2*len(corners))
+		for i, p := range boardPoints {
+			uv := project(rot, t, p)
+			res = append(res, uv.X-corners[i].X, uv.Y-corners[i].Y)
+		}
+		return res
+	}
+
+	// gauss newton on the reprojection error, the homography gets close but isn't optimal
+	apply := func(rot mat3, t r3.Vector, x []float64) (mat3, r3.Vector) {
+		return rodrigues(r3.Vector{x[0], x[1], x[2]}).mul(rot), t.Add(r3.Vector{x[3], x[4], x[5]})
+	}
+	for range 10 {
+		r0 := residuals(rot, t)
+		jac := mat.NewDense(len(r0), 6, nil)
+		for k := 0; k < 6; k++ {
+			step := []float64{0, 0, 0, 0, 0, 0}
+			step[k] = 1e-6
+			if k >= 3 {
+				step[k] = 1e-3
+			}
+			r1 := residuals(apply(rot, t, step))
+			for i := range r0 {
+				jac.Set(i, k, (r1[i]-r0[i])/step[k])
+			}
+		}
+
+		var x mat.VecDense
+		err := x.SolveVec(jac, mat.NewVecDense(len(r0), r0))
+		if err != nil {
+			break
+		}
+		x.ScaleVec(-1, &x)
+		rot, t = apply(rot, t, x.RawVector().Data)
+		if mat.Norm(&x, 2) < 1e-9 {
+			break
+		}
+	}
+
+	sum := 0.0
+	for _, r := range residuals(rot, t) {
+		sum += r * r
+	}
+	rms := math.Sqrt(sum / float64(len(corners)))
+
+	return rot.pose(t), rms, nil
+}
+
+// planarPoseFromHomography is the rotation and translation that put the z=0 points onto the normalized image points.
+func planarPoseFromHomography(points []r3.Vector, normalized []r2.Point) (mat3, r3.Vector, error) {
+	// center and scale the board points so the dlt is well conditioned
+	center := r3.Vector{}
+	for _, p := range points {
+		center = center.Add(p)
+	}
+	center = center.Mul(1 / float64(len(points)))
+	scale := 0.0
+	for _, p := range points {
+		scale += p.Sub(center).Norm()
+	}
+	scale = math.Sqrt2 * float64(len(points)) / scale
+
+	a := mat.NewDense(2*len(points), 9, nil)
+	for i, p := range points {
+		x, y := (p.X-center.X)*scale, (p.Y-center.Y)*scale
+		u, v := normalized[i].X, normalized[i].Y
+		a.SetRow(2*i, []float64{x, y, 1, 0, 0, 0, -u * x, -u * y, -u})
+		a.SetRow(2*i+1, []float64{0, 0, 0, x, y, 1, -v * x, -v * y, -v})
+	}
+
+	var svd mat.SVD
+	if !svd.Factorize(a, mat.SVDThin) {
+		return mat3{}, r3.Vector{}, fmt.Errorf("cannot find homography")
+	}
+	var vt mat.Dense
+	svd.VTo(&vt)
+	h := mat.Col(nil, 8, &vt)
+
+	// undo the board point normalization: H * [scale 0 -scale*cx; 0 scale -scale*cy; 0 0 1]
+	col := func(c int) r3.Vector { return r3.Vector{h[c], h[3+c], h[6+c]} }
+	h1 := col(0).Mul(scale)
+	h2 := col(1).Mul(scale)
+	h3 := col(2).Sub(col(0).Mul(scale * center.X)).Sub(col(1).Mul(scale * center.Y))
+
+	lambda := 2 / (h1.Norm() + h2.Norm())
+	if h3.Z < 0 {
+		lambda = -lambda
+	}
+	r1, r2, t := h1.Mul(lambda), h2.Mul(lambda), h3.Mul(lambda)
+
+	// closest real rotation to [r1 r2 r1xr2]
+	approx := mat3FromCols(r1, r2, r1.Cross(r2))
+	var rsvd mat.SVD
+	if !rsvd.Factorize(mat.NewDense(3, 3, approx[:]), mat.SVDFull) {
+		return mat3{}, r3.Vector{}, fmt.Errorf("cannot find rotation")
+	}
+	var u, v mat.Dense
+	rsvd.UTo(&u)
+	rsvd.VTo(&v)
+	var rd mat.Dense
+	rd.Mul(&u, v.T())
+	if mat.Det(&rd) < 0 {
+		// flip the last column of u
+		for r := 0; r < 3; r++ {
+			u.Set(r, 2, -u.At(r, 2))
+		}
+		rd.Mul(&u, v.T())
+	}
+
+	rot := mat3{}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			rot[3*r+c] = rd.At(r, c)
+		}
+	}
+	return rot, t, nil
+}
+
+type grayImage struct {
+	w, h int
+	pix  []float64
+}
+
+func newGrayImage(img image.Image) *grayImage {
+	b := img.Bounds()
+	g := &grayImage{w: b.Dx(), h: b.Dy(), pix: make([]float64, b.Dx()*b.Dy())}
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			r, gg, bb, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			g.pix[y*g.w+x] = (.299*float64(r) + .587*float64(gg) + .114*float64(bb)) / 65535
+		}
+	}
+	return g
+}
+
+func (g *grayImage) at(x, y int) float64 {
+	x = max(0, min(g.w-1, x))
+	y = max(0, min(g.h-1, y))
+	return g.pix[y*g.w+x]
+}
+
+// atPoint is bilinear.
+func (g *grayImage) atPoint(p r2.Point) float64 {
+	x0, y0 := int(math.Floor(p.X)), int(math.Floor(p.Y))
+	fx, fy := p.X-float64(x0), p.Y-float64(y0)
+	return g.at(x0, y0)*(1-fx)*(1-fy) + g.at(x0+1, y0)*fx*(1-fy) + g.at(x0, y0+1)*(1-fx)*fy + g.at(x0+1, y0+1)*fx*fy
+}
+
+func (g *grayImage) blur(sigma float64) {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	tmp := make([]float64, len(g.pix))
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			v := 0.0
+			for i, k := range kernel {
+				v += k * g.at(x+i-radius, y)
+			}
+			tmp[y*g.w+x] = v
+		}
+	}
+	copy(g.pix, tmp)
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			v := 0.0
+			for i, k := range kernel {
+				v += k * g.at(x, y+i-radius)
+			}
+			tmp[y*g.w+x] = v
+		}
+	}
+	g.pix = tmp
+}
+
+type checkerCandidate struct {
+	p     r2.Point
+	score float64
+}
+
+// saddlePoints finds where the image curves up one way and down the other, which is what checkerboard
+// corners look like, strongest first.
+func (g *grayImage) saddlePoints() []checkerCandidate {
+	hessian := func(x, y int) (float64, float64, float64) {
+		c := g.at(x, y)
+		ixx := g.at(x+1, y) - 2*c + g.at(x-1, y)
+		iyy := g.at(x, y+1) - 2*c + g.at(x, y-1)
+		ixy := (g.at(x+1, y+1) - g.at(x+1, y-1) - g.at(x-1, y+1) + g.at(x-1, y-1)) / 4
+		return ixx, iyy, ixy
+	}
+
+	response := make([]float64, len(g.pix))
+	best := 0.0
+	for y := 1; y < g.h-1; y++ {
+		for x := 1; x < g.w-1; x++ {
+			ixx, iyy, ixy := hessian(x, y)
+			det := ixx*iyy - ixy*ixy
+			if det < 0 {
+				response[y*g.w+x] = -det
+				best = math.Max(best, -det)
+			}
+		}
+	}
+
+	threshold := best * .05
+	radius := int(math.Ceil(2 * checkerBlur))
+
+	cands := []checkerCandidate{}
+	for y := radius; y < g.h-radius; y++ {
+		for x := radius; x < g.w-radius; x++ {
+			r := response[y*g.w+x]
+			if r <= threshold {
+				continue
+			}
+			isMax := true
+			for dy := -radius; dy <= radius && isMax; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					if (dx != 0 || dy != 0) && response[(y+dy)*g.w+x+dx] > r {
+						isMax = false
+						break
+					}
+				}
+			}
+			if !isMax {
+				continue
+			}
+
+			p := g.refineCorner(r2.Point{X: float64(x), Y: float64(y)})
+			if !g.isXCorner(p) {
+				continue
+			}
+			cands = append(cands, checkerCandidate{p: p, score: r})
+		}
+	}
+
+	sort.Slice(cands, func(i, j int) bool { return cands[i].score > cands[j].score })
+	if len(cands) > checkerMaxCandidates {
+		cands = cands[:checkerMaxCandidates]
+	}
+	return cands
+}
+
+// refineCorner moves p to sub pixel accuracy. Edges near a corner all point at it, so it's the point
+// every nearby gradient is perpendicular to the direction to.
+func (g *grayImage) refineCorner(p r2.Point) r2.Point {
+	radius := int(math.Ceil(2*checkerBlur)) + 1
+	for range 10 {
+		cx, cy := int(math.Round(p.X)), int(math.Round(p.Y))
+		var axx, axy, ayy, bx, by float64
+		for y := cy - radius; y <= cy+radius; y++ {
+			for x := cx - radius; x <= cx+radius; x++ {
+				gx := (g.at(x+1, y) - g.at(x-1, y)) / 2
+				gy := (g.at(x, y+1) - g.at(x, y-1)) / 2
+				dx, dy := float64(x)-p.X, float64(y)-p.Y
+				w := math.Exp(-(dx*dx + dy*dy) / float64(2*radius*radius))
+				axx += w * gx * gx
+				axy += w * gx * gy
+				ayy += w * gy * gy
+				bx += w * (gx*gx*float64(x) + gx*gy*float64(y))
+				by += w * (gx*gy*float64(x) + gy*gy*float64(y))
+			}
+		}
+		det := axx*ayy - axy*axy
+		if math.Abs(det) < 1e-12 {
+			return p
+		}
+		next := r2.Point{X: (ayy*bx - axy*by) / det, Y: (axx*by - axy*bx) / det}
+		if next.Sub(p).Norm() > float64(radius) {
+			return p
+		}
+		done := next.Sub(p).Norm() < .01
+		p = next
+		if done {
+			break
+		}
+	}
+	return p
+}
+
+// isXCorner checks going around p is dark, light, dark, light, which rules out
+// where the edge of the board meets its border and other saddle shaped things.
+func (g *grayImage) isXCorner(p r2.Point) bool {
+	const samples = 32
+	values := make([]float64, samples)
+	mean := 0.0
+	for i := range values {
+		a := 2 * math.Pi * float64(i) / samples
+		values[i] = g.atPoint(p.Add(r2.Point{X: math.Cos(a), Y: math.Sin(a)}.Mul(2*checkerBlur + 1)))
+		mean += values[i] / samples
+	}
+
+	changes := 0
+	for i, v := range values {
+		if (v > mean) != (values[(i+1)%samples] > mean) {
+			changes++
+		}
+	}
+	return changes == 4
+}
+
+type gridIndex [2]int
+
+// growCheckerGrid starts at seed and its two closest neighbors and keeps adding corners
+// that are where the grid says the next one should be.
+func growCheckerGrid(cands []checkerCandidate, seed int) map[gridIndex]int {
+	p0 := cands[seed].p
+
+	n1, d1 := -1, math.Inf(1)
+	for i, c := range cands {
+		d := c.p.Sub(p0).Norm()
+		if i != seed && d < d1 {
+			n1, d1 = i, d
+		}
+	}
+	if n1 < 0 {
+		return nil
+	}
+	u := cands[n1].p.Sub(p0)
+
+	n2, d2 := -1, math.Inf(1)
+	for i, c := range cands {
+		if i == seed || i == n1 {
+			continue
+		}
+		v := c.p.Sub(p0)
+		d := v.Norm()
+		if d < .5*d1 || d > 2*d1 || math.Abs(u.Dot(v))/(d1*d) > .5 {
+			continue
+		}
+		if d < d2 {
+			n2, d2 = i, d
+		}
+	}
+	if n2 < 0 {
+		return nil
+	}
+	v := cands[n2].p.Sub(p0)
+
+	grid := map[gridIndex]int{{0, 0}: seed, {1, 0}: n1, {0, 1}: n2}
+	used := map[int]bool{seed: true, n1: true, n2: true}
+	queue := []gridIndex{{0, 0}, {1, 0}, {0, 1}}
+
+	// step is the best guess for the vector from at to at+dir
+	step := func(at, dir gridIndex) r2.Point {
+		if b, ok := grid[gridIndex{at[0] - dir[0], at[1] - dir[1]}]; ok {
+			return cands[grid[at]].p.Sub(cands[b].p)
+		}
+		// a neighbor on the side that has already gone this way
+		for _, side := range []gridIndex{{dir[1], dir[0]}, {-dir[1], -dir[0]}} {
+			from, ok1 := grid[gridIndex{at[0] + side[0], at[1] + side[1]}]
+			to, ok2 := grid[gridIndex{at[0] + side[0] + dir[0], at[1] + side[1] + dir[1]}]
+			if ok1 && ok2 {
+				return cands[to].p.Sub(cands[from].p)
+			}
+		}
+		if dir[0] != 0 {
+			return u.Mul(float64(dir[0]))
+		}
+		return v.Mul(float64(dir[1]))
+	}
+
+	for len(queue) > 0 {
+		at := queue[0]
+		queue = queue[1:]
+		p := cands[grid[at]].p
+
+		for _, dir := range []gridIndex{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			next := gridIndex{at[0] + dir[0], at[1] + dir[1]}
+			if _, ok := grid[next]; ok {
+				continue
+			}
+
+			s := step(at, dir)
+			predicted := p.Add(s)
+			found, bestD := -1, .35*s.Norm()
+			for i, c := range cands {
+				if used[i] {
+					continue
+				}
+				d := c.p.Sub(predicted).Norm()
+				if d < bestD {
+					found, bestD = i, d
+				}
+			}
+			if found < 0 {
+				continue
+			}
+
+			grid[next] = found
+			used[found] = true
+			queue = append(queue, next)
+		}
+	}
+
+	return grid
+}
+
+// orderCheckerGrid checks grid is a full board that looks like a checkerboard, and puts the corners in board order.
+func orderCheckerGrid(g *grayImage, cands []checkerCandidate, grid map[gridIndex]int, board CheckerboardConfig) ([]r2.Point, bool) {
+	if len(grid) != board.Cols*board.Rows {
+		return nil, false
+	}
+
+	minI, minJ, maxI, maxJ := math.MaxInt, math.MaxInt, math.MinInt, math.MinInt
+	for k := range grid {
+		minI, maxI = min(minI, k[0]), max(maxI, k[0])
+		minJ, maxJ = min(minJ, k[1]), max(maxJ, k[1])
+	}
+	nI, nJ := maxI-minI+1, maxJ-minJ+1
+
+	var swap bool
+	switch {
+	case nI == board.Cols && nJ == board.Rows:
+		swap = false
+	case nI == board.Rows && nJ == board.Cols:
+		swap = true
+	default:
+		return nil, false
+	}
+
+	// corners for each of the ways the board axes could map to the grid's
+	order := func(flipX, flipY bool) []r2.Point {
+		pts := make([]r2.Point, 0, board.Cols*board.Rows)
+		for y := 0; y < board.Rows; y++ {
+			for x := 0; x < board.Cols; x++ {
+				bx, by := x, y
+				if flipX {
+					bx = board.Cols - 1 - x
+				}
+				if flipY {
+					by = board.Rows - 1 - y
+				}
+				k := gridIndex{minI + bx, minJ + by}
+				if swap {
+					k = gridIndex{minI + by, minJ + bx}
+				}
+				pts = append(pts, cands[grid[k]].p)
+			}
+		}
+		return pts
+	}
+
+	cellCenter := func(pts []r2.Point, x, y int) r2.Point {
+		i := y*board.Cols + x
+		return pts[i].Add(pts[i+1]).Add(pts[i+board.Cols]).Add(pts[i+board.Cols+1]).Mul(.25)
+	}
+
+	for _, flipX := range []bool{false, true} {
+		for _, flipY := range []bool{false, true} {
+			pts := order(flipX, flipY)
+
+			// z has to point away from the camera, image y is down
+			xStep := pts[1].Sub(pts[0])
+			yStep := pts[board.Cols].Sub(pts[0])
+			if xStep.Cross(yStep) <= 0 {
+				continue
+			}
+
+			// squares have to alternate, and the first one has to be dark
+			even, odd := []float64{}, []float64{}
+			for y := 0; y < board.Rows-1; y++ {
+				for x := 0; x < board.Cols-1; x++ {
+					v := g.atPoint(cellCenter(pts, x, y))
+					if (x+y)%2 == 0 {
+						even = append(even, v)
+					} else {
+						odd = append(odd, v)
+					}
+				}
+			}
+			sort.Float64s(even)
+			sort.Float64s(odd)
+			if even[len(even)-1] >= odd[0] {
+				continue
+			}
+
+			return pts, true
+		}
+	}
+
+	return nil, false
+}
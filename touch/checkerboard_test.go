@@ -0,0 +1,102 @@
+package touch
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+var checkerTestProps = camera.Properties{
+	IntrinsicParams: &transform.PinholeCameraIntrinsics{Width: 640, Height: 480, Fx: 600, Fy: 600, Ppx: 320, Ppy: 240},
+}
+
+// renderCheckerboard draws board at boardPose (in the camera frame) with a white border, on a gray background.
+func renderCheckerboard(board CheckerboardConfig, boardPose spatialmath.Pose) image.Image {
+	in := checkerTestProps.IntrinsicParams
+	img := image.NewGray(image.Rect(0, 0, in.Width, in.Height))
+
+	rot := mat3FromOrientation(boardPose.Orientation())
+	inv := rot.transpose()
+	t := boardPose.Point()
+	normal := rot.mulVec(r3.Vector{Z: 1})
+
+	shade := func(u, v float64) float64 {
+		d := r3.Vector{X: (u - in.Ppx) / in.Fx, Y: (v - in.Ppy) / in.Fy, Z: 1}
+		s := normal.Dot(t) / normal.Dot(d)
+		b := inv.mulVec(d.Mul(s).Sub(t))
+		i := int(math.Floor(b.X/board.SquareMM)) + 1
+		j := int(math.Floor(b.Y/board.SquareMM)) + 1
+		switch {
+		case i < -1 || j < -1 || i > board.Cols+1 || j > board.Rows+1:
+			return 128
+		case i < 0 || j < 0 || i > board.Cols || j > board.Rows:
+			return 230
+		case (i+j)%2 == 0:
+			return 20
+		default:
+			return 230
+		}
+	}
+
+	for y := 0; y < in.Height; y++ {
+		for x := 0; x < in.Width; x++ {
+			v := 0.0
+			for _, dy := range []float64{-.25, .25} {
+				for _, dx := range []float64{-.25, .25} {
+					v += shade(float64(x)+dx, float64(y)+dy)
+				}
+			}
+			img.SetGray(x, y, color.Gray{uint8(v / 4)})
+		}
+	}
+	return img
+}
+
+func TestCheckerboardConfig(t *testing.T) {
+	test.That(t, (&CheckerboardConfig{Cols: 7, Rows: 6, SquareMM: 20}).Validate(), test.ShouldBeNil)
+	test.That(t, (&CheckerboardConfig{Cols: 6, Rows: 6, SquareMM: 20}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&CheckerboardConfig{Cols: 7, Rows: 2, SquareMM: 20}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&CheckerboardConfig{Cols: 7, Rows: 6}).Validate(), test.ShouldNotBeNil)
+}
+
+func TestFindCheckerboard(t *testing.T) {
+	board := CheckerboardConfig{Cols: 7, Rows: 6, SquareMM: 20}
+
+	for _, boardPose := range []spatialmath.Pose{
+		spatialmath.NewPose(r3.Vector{-60, -50, 500}, &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: 10}),
+		spatialmath.NewPose(r3.Vector{-40, -60, 450}, &spatialmath.OrientationVectorDegrees{OX: .3, OY: -.2, OZ: 1, Theta: -30}),
+		// upside down, the first corner has to still be the same one on the board
+		spatialmath.NewPose(r3.Vector{60, 50, 520}, &spatialmath.OrientationVectorDegrees{OX: -.2, OZ: 1, Theta: 175}),
+	} {
+		img := renderCheckerboard(board, boardPose)
+
+		corners, err := FindCheckerboard(img, board)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(corners), test.ShouldEqual, 42)
+
+		in := checkerTestProps.IntrinsicParams
+		for i, p := range board.points() {
+			c := spatialmath.Compose(boardPose, spatialmath.NewPoseFromPoint(p)).Point()
+			expected := r2.Point{X: in.Fx*c.X/c.Z + in.Ppx, Y: in.Fy*c.Y/c.Z + in.Ppy}
+			test.That(t, corners[i].Sub(expected).Norm(), test.ShouldBeLessThan, .5)
+		}
+
+		pose, rms, err := CheckerboardPose(corners, board, checkerTestProps)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rms, test.ShouldBeLessThan, .3)
+		test.That(t, pose.Point().Sub(boardPose.Point()).Norm(), test.ShouldBeLessThan, 2)
+		test.That(t, spatialmath.OrientationAlmostEqualEps(pose.Orientation(), boardPose.Orientation(), .01), test.ShouldBeTrue)
+	}
+
+	_, err := FindCheckerboard(image.NewGray(image.Rect(0, 0, 100, 100)), board)
+	test.That(t, err, test.ShouldNotBeNil)
+}
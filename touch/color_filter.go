@@ -0,0 +1,117 @@
+package touch
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+const (
+	ColorSpaceRGB = "rgb"
+	ColorSpaceHSV = "hsv"
+	ColorSpaceLab = "lab"
+)
+
+type ColorFilter struct {
+	Color color.RGBA
+	// Distance is euclidean for rgb (0-441) and delta-E 2000 for lab (0-100)
+	Distance float64
+
+	// Space is rgb (default), hsv, or lab
+	Space string `json:"space,omitempty"`
+
+	// for hsv, how far each channel can be from Color's. hue is in degrees, saturation and value are 0-1.
+	// 0 means that channel isn't checked.
+	HueRange        float64 `json:"hue_range,omitempty"`
+	SaturationRange float64 `json:"saturation_range,omitempty"`
+	ValueRange      float64 `json:"value_range,omitempty"`
+
+	// Exclude drops colors that match instead of keeping them
+	Exclude bool `json:"exclude,omitempty"`
+}
+
+func (cf *ColorFilter) Validate() error {
+	switch cf.Space {
+	case "", ColorSpaceRGB, ColorSpaceLab:
+	case ColorSpaceHSV:
+		if cf.HueRange == 0 && cf.SaturationRange == 0 && cf.ValueRange == 0 {
+			return fmt.Errorf("hsv color filter needs at least one of hue_range, saturation_range, value_range")
+		}
+	default:
+		return fmt.Errorf("unknown color space [%s]", cf.Space)
+	}
+	if cf.Distance < 0 || cf.HueRange < 0 || cf.SaturationRange < 0 || cf.ValueRange < 0 {
+		return fmt.Errorf("color filter distance and ranges cannot be negative")
+	}
+	return nil
+}
+
+// Matches is true if c is close enough to Color, ignoring Exclude.
+func (cf *ColorFilter) Matches(c color.Color) bool {
+	switch cf.Space {
+	case ColorSpaceHSV:
+		h1, s1, v1 := toColorful(cf.Color).Hsv()
+		h2, s2, v2 := toColorful(c).Hsv()
+		if cf.HueRange > 0 && HueDistance(h1, h2) > cf.HueRange {
+			return false
+		}
+		if cf.SaturationRange > 0 && math.Abs(s1-s2) > cf.SaturationRange {
+			return false
+		}
+		if cf.ValueRange > 0 && math.Abs(v1-v2) > cf.ValueRange {
+			return false
+		}
+		return true
+	case ColorSpaceLab:
+		return DeltaE2000(cf.Color, c) <= cf.Distance
+	default:
+		return EuclideanRGB(cf.Color, c) <= cf.Distance
+	}
+}
+
+// ColorFiltersMatch is true if c matches all of the filters that are not Exclude and none that are.
+func ColorFiltersMatch(filters []ColorFilter, c color.Color) bool {
+	for _, cf := range filters {
+		if cf.Matches(c) == cf.Exclude {
+			return false
+		}
+	}
+	return true
+}
+
+// toColorful ignores alpha, configs often leave it as 0.
+func toColorful(c color.Color) colorful.Color {
+	r, g, b, _ := c.RGBA()
+	return colorful.Color{R: float64(r) / 65535, G: float64(g) / 65535, B: float64(b) / 65535}
+}
+
+func EuclideanRGB(c1, c2 color.Color) float64 {
+	r1, g1, b1, _ := c1.RGBA()
+	r2, g2, b2, _ := c2.RGBA()
+
+	// RGBA() returns uint32 in range [0, 65535], convert to [0, 255]
+	r1, g1, b1 = r1>>8, g1>>8, b1>>8
+	r2, g2, b2 = r2>>8, g2>>8, b2>>8
+
+	dr := int(r1) - int(r2)
+	dg := int(g1) - int(g2)
+	db := int(b1) - int(b2)
+
+	return math.Sqrt(float64(dr*dr + dg*dg + db*db))
+}
+
+// DeltaE2000 is the CIEDE2000 distance between c1 and c2 on the usual 0-100 scale.
+func DeltaE2000(c1, c2 color.Color) float64 {
+	return toColorful(c1).DistanceCIEDE2000(toColorful(c2)) * 100
+}
+
+// HueDistance is the distance in degrees between two hues, going the short way around.
+func HueDistance(h1, h2 float64) float64 {
+	d := math.Mod(math.Abs(h1-h2), 360)
+	if d > 180 {
+		return 360 - d
+	}
+	return d
+}
@@ -0,0 +1,46 @@
+package touch
+
+import (
+	"image/color"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestColorFilter(t *testing.T) {
+	orange := color.RGBA{255, 140, 0, 0}
+	darkOrange := color.RGBA{150, 82, 0, 255}
+	green := color.RGBA{20, 200, 30, 255}
+
+	rgb := ColorFilter{Color: orange, Distance: 50}
+	test.That(t, rgb.Validate(), test.ShouldBeNil)
+	test.That(t, rgb.Matches(orange), test.ShouldBeTrue)
+	// in the afternoon it is too dark for rgb
+	test.That(t, rgb.Matches(darkOrange), test.ShouldBeFalse)
+
+	hsv := ColorFilter{Color: orange, Space: ColorSpaceHSV, HueRange: 10, SaturationRange: .2}
+	test.That(t, hsv.Validate(), test.ShouldBeNil)
+	test.That(t, hsv.Matches(darkOrange), test.ShouldBeTrue)
+	test.That(t, hsv.Matches(green), test.ShouldBeFalse)
+
+	lab := ColorFilter{Color: orange, Space: ColorSpaceLab, Distance: 5}
+	test.That(t, lab.Validate(), test.ShouldBeNil)
+	test.That(t, lab.Matches(color.RGBA{250, 138, 5, 255}), test.ShouldBeTrue)
+	test.That(t, lab.Matches(green), test.ShouldBeFalse)
+
+	noGreen := ColorFilter{Color: green, Space: ColorSpaceHSV, HueRange: 30, Exclude: true}
+	test.That(t, ColorFiltersMatch([]ColorFilter{noGreen}, orange), test.ShouldBeTrue)
+	test.That(t, ColorFiltersMatch([]ColorFilter{noGreen}, green), test.ShouldBeFalse)
+	test.That(t, ColorFiltersMatch([]ColorFilter{hsv, noGreen}, darkOrange), test.ShouldBeTrue)
+	test.That(t, ColorFiltersMatch(nil, green), test.ShouldBeTrue)
+
+	test.That(t, (&ColorFilter{Space: ColorSpaceHSV}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&ColorFilter{Space: "cmyk"}).Validate(), test.ShouldNotBeNil)
+}
+
+func TestHueDistance(t *testing.T) {
+	test.That(t, HueDistance(10, 350), test.ShouldAlmostEqual, 20)
+	test.That(t, HueDistance(350, 10), test.ShouldAlmostEqual, 20)
+	test.That(t, HueDistance(90, 270), test.ShouldAlmostEqual, 180)
+	test.That(t, HueDistance(30, 40), test.ShouldAlmostEqual, 10)
+}
@@ -0,0 +1,304 @@
+package touch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/erh/vmodutils/file_utils"
+)
+
+// HandEyeSample is one arm position: where the gripper was relative to the arm's base,
+// and where the calibration target was relative to the camera.
+type HandEyeSample struct {
+	Gripper spatialmath.Pose
+	Target  spatialmath.Pose
+}
+
+// HandEyeResult is where the camera is relative to the gripper.
+type HandEyeResult struct {
+	Camera  spatialmath.Pose
+	Samples int
+	// TranslationErrorMM and RotationErrorDeg are how much the target seems to move (it shouldn't) between samples
+	// using Camera, so they are a good measure of how well everything fit.
+	TranslationErrorMM float64
+	RotationErrorDeg   float64
+}
+
+// FrameConfig is Camera as a frame config with parent as the parent, which is normally the arm.
+func (r *HandEyeResult) FrameConfig(parent string) map[string]interface{} {
+	p := r.Camera.Point()
+	ov := r.Camera.Orientation().OrientationVectorDegrees()
+	return map[string]interface{}{
+		"parent": parent,
+		"translation": map[string]interface{}{
+			"x": p.X,
+			"y": p.Y,
+			"z": p.Z,
+		},
+		"orientation": map[string]interface{}{
+			"type": "ov_degrees",
+			"value": map[string]interface{}{
+				"x":  ov.OX,
+				"y":  ov.OY,
+				"z":  ov.OZ,
+				"th": ov.Theta,
+			},
+		},
+	}
+}
+
+func (r *HandEyeResult) toMap(parent string) map[string]interface{} {
+	return map[string]interface{}{
+		"frame":                r.FrameConfig(parent),
+		"samples":              r.Samples,
+		"translation_error_mm": r.TranslationErrorMM,
+		"rotation_error_deg":   r.RotationErrorDeg,
+	}
+}
+
+// handEyeMinRotation is how much (radians) the gripper has to rotate between two samples for the pair to help.
+const handEyeMinRotation = math.Pi / 180
+
+// SolveHandEye finds X in AX=XB for a camera mounted on the gripper, with Park and Martin's method.
+// The gripper has to rotate around at least two different axes across the samples.
+func SolveHandEye(samples []HandEyeSample) (*HandEyeResult, error) {
+	if len(samples) < 3 {
+		return nil, fmt.Errorf("need at least 3 samples, have %d", len(samples))
+	}
+
+	type motionPair struct {
+		a, b spatialmath.Pose
+	}
+	pairs := []motionPair{}
+	for i := range samples {
+		for j := i + 1; j < len(samples); j++ {
+			a := spatialmath.Compose(spatialmath.PoseInverse(samples[j].Gripper), samples[i].Gripper)
+			b := spatialmath.Compose(samples[j].Target, spatialmath.PoseInverse(samples[i].Target))
+			if spatialmath.QuatToR3AA(a.Orientation().Quaternion()).Norm() < handEyeMinRotation {
+				continue
+			}
+			pairs = append(pairs, motionPair{a, b})
+		}
+	}
+	if len(pairs) < 2 {
+		return nil, fmt.Errorf("the gripper needs to rotate more between positions, only %d usable pairs", len(pairs))
+	}
+
+	// rotation: alpha = R * beta for every pair
+	m := mat.NewDense(3, 3, nil)
+	for _, p := range pairs {
+		alpha := spatialmath.QuatToR3AA(p.a.Orientation().Quaternion())
+		beta := spatialmath.QuatToR3AA(p.b.Orientation().Quaternion())
+		var outer mat.Dense
+		outer.Outer(1, mat.NewVecDense(3, []float64{beta.X, beta.Y, beta.Z}), mat.NewVecDense(3, []float64{alpha.X, alpha.Y, alpha.Z}))
+		m.Add(m, &outer)
+	}
+
+	// R = (M^T M)^(-1/2) M^T
+	var mtm mat.SymDense
+	mtm.SymOuterK(1, m.T())
+	var eig mat.EigenSym
+	if !eig.Factorize(&mtm, true) {
+		return nil, fmt.Errorf("cannot solve hand eye rotation")
+	}
+	values := eig.Values(nil)
+	if values[0] < 1e-6*values[2] {
+		return nil, fmt.Errorf("the gripper needs to rotate around more than one axis between positions")
+	}
+	var vecs mat.Dense
+	eig.VectorsTo(&vecs)
+	invSqrt := mat.NewDense(3, 3, nil)
+	for k := 0; k < 3; k++ {
+		var outer mat.Dense
+		col := vecs.ColView(k)
+		outer.Outer(1/math.Sqrt(values[k]), col, col)
+		invSqrt.Add(invSqrt, &outer)
+	}
+	var rd mat.Dense
+	rd.Mul(invSqrt, m.T())
+	rx := mat3{}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			rx[3*r+c] = rd.At(r, c)
+		}
+	}
+
+	// translation: (Ra - I) t = R tb - ta for every pair
+	a := mat.NewDense(3*len(pairs), 3, nil)
+	b := mat.NewVecDense(3*len(pairs), nil)
+	for i, p := range pairs {
+		ra := mat3FromOrientation(p.a.Orientation())
+		rhs := rx.mulVec(p.b.Point()).Sub(p.a.Point())
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				v := ra[3*r+c]
+				if r == c {
+					v--
+				}
+				a.Set(3*i+r, c, v)
+			}
+		}
+		b.SetVec(3*i, rhs.X)
+		b.SetVec(3*i+1, rhs.Y)
+		b.SetVec(3*i+2, rhs.Z)
+	}
+	var t mat.VecDense
+	err := t.SolveVec(a, b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot solve hand eye translation: %w", err)
+	}
+
+	res := &HandEyeResult{
+		Camera:  rx.pose(r3.Vector{X: t.AtVec(0), Y: t.AtVec(1), Z: t.AtVec(2)}),
+		Samples: len(samples),
+	}
+
+	// the target is fixed, so every sample should put it in the same place
+	targets := make([]spatialmath.Pose, len(samples))
+	center := r3.Vector{}
+	for i, s := range samples {
+		targets[i] = spatialmath.Compose(spatialmath.Compose(s.Gripper, res.Camera), s.Target)
+		center = center.Add(targets[i].Point())
+	}
+	center = center.Mul(1 / float64(len(samples)))
+	for _, t := range targets {
+		res.TranslationErrorMM += t.Point().Sub(center).Norm2()
+		angle := spatialmath.QuatToR3AA(spatialmath.PoseBetween(targets[0], t).Orientation().Quaternion()).Norm()
+		res.RotationErrorDeg += angle * angle
+	}
+	res.TranslationErrorMM = math.Sqrt(res.TranslationErrorMM / float64(len(samples)))
+	res.RotationErrorDeg = math.Sqrt(res.RotationErrorDeg/float64(len(samples))) * 180 / math.Pi
+
+	return res, nil
+}
+
+// HandEyeSampleFromImage finds board in img and makes a sample with it and gripper.
+// Also returns the RMS reprojection error in pixels.
+func HandEyeSampleFromImage(img image.Image, gripper spatialmath.Pose, board CheckerboardConfig, props camera.Properties) (HandEyeSample, float64, error) {
+	corners, err := FindCheckerboard(img, board)
+	if err != nil {
+		return HandEyeSample{}, 0, err
+	}
+	target, rms, err := CheckerboardPose(corners, board, props)
+	if err != nil {
+		return HandEyeSample{}, 0, err
+	}
+	return HandEyeSample{Gripper: gripper, Target: target}, rms, nil
+}
+
+type handEyeSampleFile struct {
+	Gripper    *referenceframe.PoseInFrame        `json:"gripper"`
+	Intrinsics *transform.PinholeCameraIntrinsics `json:"intrinsics"`
+}
+
+const (
+	handEyeSamplePrefix = "handeye_sample_"
+	handEyeImagePrefix  = "handeye_image_"
+)
+
+// writeHandEyeFiles saves what ReadHandEyeSamples needs for one position.
+func writeHandEyeFiles(dirPath string, pos int, img image.Image, gripper *referenceframe.PoseInFrame, props camera.Properties) error {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+	err = file_utils.SaveFile(buf.Bytes(), dirPath, handEyeImagePrefix+strconv.Itoa(pos)+".png", time.Now())
+	if err != nil {
+		return err
+	}
+
+	return file_utils.SaveJsonFile(handEyeSampleFile{gripper, props.IntrinsicParams}, dirPath, handEyeSamplePrefix+strconv.Itoa(pos)+".json", time.Now())
+}
+
+// ReadHandEyeSamples finds board in every position written to dir, one run's directory, by the hand-eye-calibration service.
+// Positions where the board can't be found are skipped and returned as errors.
+func ReadHandEyeSamples(dir string, board CheckerboardConfig) ([]HandEyeSample, map[int]error, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+handEyeSamplePrefix+"*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no hand eye samples in %s", dir)
+	}
+
+	positions := map[int]string{}
+	for _, fn := range files {
+		base := filepath.Base(fn)
+		idx := base[strings.LastIndex(base, handEyeSamplePrefix)+len(handEyeSamplePrefix):]
+		pos, err := strconv.Atoi(strings.TrimSuffix(idx, ".json"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad hand eye sample file name %s", fn)
+		}
+		if positions[pos] != "" {
+			return nil, nil, fmt.Errorf("%s has more than one run in it, use one run's directory", dir)
+		}
+		positions[pos] = fn
+	}
+	order := []int{}
+	for pos := range positions {
+		order = append(order, pos)
+	}
+	sort.Ints(order)
+
+	samples := []HandEyeSample{}
+	skipped := map[int]error{}
+	for _, pos := range order {
+		data, err := os.ReadFile(positions[pos])
+		if err != nil {
+			return nil, nil, err
+		}
+		var sf handEyeSampleFile
+		err = json.Unmarshal(data, &sf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read %s: %w", positions[pos], err)
+		}
+		if sf.Gripper == nil || sf.Intrinsics == nil {
+			return nil, nil, fmt.Errorf("%s is missing the gripper or intrinsics", positions[pos])
+		}
+
+		images, err := filepath.Glob(filepath.Join(dir, "*"+handEyeImagePrefix+strconv.Itoa(pos)+".png"))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(images) != 1 {
+			return nil, nil, fmt.Errorf("need one image for hand eye sample %d, have %d", pos, len(images))
+		}
+		f, err := os.Open(images[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read %s: %w", images[0], err)
+		}
+
+		s, _, err := HandEyeSampleFromImage(img, sf.Gripper.Pose(), board, camera.Properties{IntrinsicParams: sf.Intrinsics})
+		if err != nil {
+			skipped[pos] = err
+			continue
+		}
+		samples = append(samples, s)
+	}
+
+	return samples, skipped, nil
+}
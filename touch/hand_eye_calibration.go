@@ -0,0 +1,213 @@
+package touch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/components/camera"
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
+	"go.viam.com/utils/trace"
+
+	"github.com/erh/vmodutils"
+	"github.com/erh/vmodutils/file_utils"
+)
+
+var HandEyeCalibrationModel = vmodutils.NamespaceFamily.WithModel("hand-eye-calibration")
+
+func init() {
+	resource.RegisterService(
+		generic.API,
+		HandEyeCalibrationModel,
+		resource.Registration[resource.Resource, *HandEyeCalibrationConfig]{
+			Constructor: newHandEyeCalibration,
+		})
+}
+
+type HandEyeCalibrationConfig struct {
+	Switch                       string             `json:"switch"`
+	Arm                          string             `json:"arm"`
+	Camera                       string             `json:"camera"`
+	Board                        CheckerboardConfig `json:"board"`
+	SleepSeconds                 float64            `json:"sleep_seconds,omitempty"`
+	WriteFilesToCaptureDirectory bool               `json:"write_files_to_capture_directory,omitempty"`
+}
+
+func (c *HandEyeCalibrationConfig) Validate(path string) ([]string, []string, error) {
+	if c.Switch == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "switch")
+	}
+	if c.Arm == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "arm")
+	}
+	if c.Camera == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	err := c.Board.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{c.Switch, c.Arm, c.Camera}, nil, nil
+}
+
+func (c *HandEyeCalibrationConfig) sleepTime() time.Duration {
+	if c.SleepSeconds <= 0 {
+		return time.Second
+	}
+	return time.Duration(c.SleepSeconds * float64(time.Second))
+}
+
+func newHandEyeCalibration(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (resource.Resource, error) {
+	newConf, err := resource.NativeConfig[*HandEyeCalibrationConfig](config)
+	if err != nil {
+		return nil, err
+	}
+
+	hec := &HandEyeCalibration{
+		name:   config.ResourceName(),
+		cfg:    newConf,
+		logger: logger,
+	}
+
+	hec.positions, err = toggleswitch.FromProvider(deps, newConf.Switch)
+	if err != nil {
+		return nil, err
+	}
+
+	hec.arm, err = arm.FromProvider(deps, newConf.Arm)
+	if err != nil {
+		return nil, err
+	}
+
+	hec.cam, err = camera.FromProvider(deps, newConf.Camera)
+	if err != nil {
+		return nil, err
+	}
+
+	return hec, nil
+}
+
+type HandEyeCalibration struct {
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	name   resource.Name
+	cfg    *HandEyeCalibrationConfig
+	logger logging.Logger
+
+	positions toggleswitch.Switch
+	arm       arm.Arm
+	cam       camera.Camera
+}
+
+func (hec *HandEyeCalibration) Name() resource.Name {
+	return hec.name
+}
+
+func (hec *HandEyeCalibration) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["calibrate"] == true {
+		return hec.calibrate(ctx)
+	}
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+// calibrate goes to every position of the switch, finds the board at each, and solves for where the camera is on the arm.
+func (hec *HandEyeCalibration) calibrate(ctx context.Context) (map[string]interface{}, error) {
+	props, err := hec.cam.Properties(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if props.IntrinsicParams == nil {
+		return nil, fmt.Errorf("camera %s has no intrinsics", hec.cfg.Camera)
+	}
+
+	var dirPath string
+	if hec.cfg.WriteFilesToCaptureDirectory {
+		// every run gets its own directory so ReadHandEyeSamples can't mix up runs
+		runDir := "handeye_" + time.Now().Format("20060102_150405.000")
+		if span := trace.FromContext(ctx); span != nil {
+			runDir = span.SpanContext().TraceID().String()
+		}
+		dirPath = file_utils.GetPathInCaptureDir(runDir)
+	}
+
+	numPositions, err := settablePositions(ctx, hec.positions)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := []HandEyeSample{}
+	skipped := map[string]interface{}{}
+	reprojection := []interface{}{}
+
+	for i := range numPositions {
+		err := hec.positions.SetPosition(ctx, i, waitExtra())
+		if err != nil {
+			return nil, err
+		}
+
+		// Sleep between movements to allow for any vibrations to settle
+		time.Sleep(hec.cfg.sleepTime())
+
+		gripper, err := hec.arm.EndPosition(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		images, _, err := hec.cam.Images(ctx, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get images from camera: %w", err)
+		}
+		ni, ok, err := poseImage(ctx, images, int(i))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("camera %s didn't return a color image", hec.cfg.Camera)
+		}
+		img, err := ni.Image(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if dirPath != "" {
+			err = writeHandEyeFiles(dirPath, int(i), img, referenceframe.NewPoseInFrame(hec.cfg.Arm, gripper), props)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		s, rms, err := HandEyeSampleFromImage(img, gripper, hec.cfg.Board, props)
+		if err != nil {
+			hec.logger.Warnf("skipping position %d: %v", i, err)
+			skipped[fmt.Sprintf("%d", i)] = err.Error()
+			continue
+		}
+		samples = append(samples, s)
+		reprojection = append(reprojection, rms)
+	}
+
+	res, err := SolveHandEye(samples)
+	if err != nil {
+		return nil, err
+	}
+
+	m := res.toMap(hec.cfg.Arm)
+	m["skipped"] = skipped
+	m["reprojection_error_px"] = reprojection
+
+	if dirPath != "" {
+		err = file_utils.SaveJsonFile(m, dirPath, "handeye_result.json", time.Now())
+		if err != nil {
+			return nil, err
+		}
+		m["dir"] = dirPath
+	}
+
+	return m, nil
+}
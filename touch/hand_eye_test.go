@@ -0,0 +1,155 @@
+package touch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils"
+)
+
+var (
+	// where the camera really is on the gripper
+	handEyeTestCamera = spatialmath.NewPose(r3.Vector{30, -50, 80}, &spatialmath.OrientationVectorDegrees{OX: .1, OY: .2, OZ: 1, Theta: 90})
+	// where the board is relative to the arm's base
+	handEyeTestBoard = spatialmath.NewPose(r3.Vector{500, 100, 0}, &spatialmath.OrientationVectorDegrees{OZ: -1, Theta: 20})
+	// where the board is seen from each position
+	handEyeTestTargets = []spatialmath.Pose{
+		spatialmath.NewPose(r3.Vector{-60, -50, 500}, &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: 10}),
+		spatialmath.NewPose(r3.Vector{-40, -60, 450}, &spatialmath.OrientationVectorDegrees{OX: .3, OY: -.2, OZ: 1, Theta: -30}),
+		spatialmath.NewPose(r3.Vector{-70, -40, 520}, &spatialmath.OrientationVectorDegrees{OX: -.2, OY: .3, OZ: 1, Theta: 40}),
+		spatialmath.NewPose(r3.Vector{-50, -70, 480}, &spatialmath.OrientationVectorDegrees{OX: .25, OY: .25, OZ: 1, Theta: 0}),
+	}
+)
+
+// handEyeTestGripper is where the gripper has to be for the camera to see the board at target.
+func handEyeTestGripper(target spatialmath.Pose) spatialmath.Pose {
+	return spatialmath.Compose(spatialmath.Compose(handEyeTestBoard, spatialmath.PoseInverse(target)), spatialmath.PoseInverse(handEyeTestCamera))
+}
+
+func TestSolveHandEye(t *testing.T) {
+	samples := []HandEyeSample{}
+	for _, target := range handEyeTestTargets {
+		samples = append(samples, HandEyeSample{Gripper: handEyeTestGripper(target), Target: target})
+	}
+
+	res, err := SolveHandEye(samples)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res.Samples, test.ShouldEqual, 4)
+	test.That(t, res.Camera.Point().Sub(handEyeTestCamera.Point()).Norm(), test.ShouldBeLessThan, .001)
+	test.That(t, spatialmath.OrientationAlmostEqual(res.Camera.Orientation(), handEyeTestCamera.Orientation()), test.ShouldBeTrue)
+	test.That(t, res.TranslationErrorMM, test.ShouldBeLessThan, .001)
+	test.That(t, res.RotationErrorDeg, test.ShouldBeLessThan, .001)
+
+	frame := res.FrameConfig("arm")
+	test.That(t, frame["parent"], test.ShouldEqual, "arm")
+
+	// all rotations around one axis can't pin down the camera
+	flat := []HandEyeSample{}
+	for _, theta := range []float64{0, 20, 40} {
+		target := spatialmath.NewPose(r3.Vector{-60, -50, 500}, &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: theta})
+		flat = append(flat, HandEyeSample{Gripper: handEyeTestGripper(target), Target: target})
+	}
+	_, err = SolveHandEye(flat)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = SolveHandEye(samples[:2])
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestHandEyeCalibration(t *testing.T) {
+	ctx := context.Background()
+	board := CheckerboardConfig{Cols: 7, Rows: 6, SquareMM: 20}
+
+	position := 0
+	positions := inject.NewSwitch("positions")
+	positions.GetNumberOfPositionsFunc = func(ctx context.Context, extra map[string]interface{}) (uint32, []string, error) {
+		return uint32(len(handEyeTestTargets)), nil, nil
+	}
+	positions.SetPositionFunc = func(ctx context.Context, p uint32, extra map[string]interface{}) error {
+		position = int(p)
+		return nil
+	}
+
+	fakeArm := inject.NewArm("arm")
+	fakeArm.EndPositionFunc = func(ctx context.Context, extra map[string]interface{}) (spatialmath.Pose, error) {
+		return handEyeTestGripper(handEyeTestTargets[position]), nil
+	}
+
+	cam := inject.NewCamera("cam")
+	cam.PropertiesFunc = func(ctx context.Context) (camera.Properties, error) {
+		return checkerTestProps, nil
+	}
+	cam.ImagesFunc = func(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		ni, err := camera.NamedImageFromImage(renderCheckerboard(board, handEyeTestTargets[position]), "color", utils.MimeTypePNG, data.Annotations{})
+		return []camera.NamedImage{ni}, resource.ResponseMetadata{}, err
+	}
+
+	cfg := &HandEyeCalibrationConfig{Switch: "positions", Arm: "arm", Camera: "cam", Board: board, SleepSeconds: .001}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"positions", "arm", "cam"})
+
+	res, err := newHandEyeCalibration(ctx, resource.Dependencies{
+		positions.Name(): positions,
+		fakeArm.Name():   fakeArm,
+		cam.Name():       cam,
+	}, resource.Config{
+		Name:                "calibration",
+		API:                 generic.API,
+		Model:               resource.Model{Family: vmodutils.NamespaceFamily},
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	out, err := res.DoCommand(ctx, map[string]interface{}{"calibrate": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out["samples"], test.ShouldEqual, 4)
+	test.That(t, out["translation_error_mm"], test.ShouldBeLessThan, 2)
+	test.That(t, out["skipped"], test.ShouldBeEmpty)
+
+	frame := out["frame"].(map[string]interface{})
+	translation := frame["translation"].(map[string]interface{})
+	found := r3.Vector{translation["x"].(float64), translation["y"].(float64), translation["z"].(float64)}
+	test.That(t, found.Sub(handEyeTestCamera.Point()).Norm(), test.ShouldBeLessThan, 5)
+
+	// the same thing from files
+	dir := t.TempDir()
+	for i, target := range handEyeTestTargets {
+		err := writeHandEyeFiles(dir, i, renderCheckerboard(board, target),
+			referenceframe.NewPoseInFrame("arm", handEyeTestGripper(target)), checkerTestProps)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	samples, skipped, err := ReadHandEyeSamples(dir, board)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, skipped, test.ShouldBeEmpty)
+	test.That(t, len(samples), test.ShouldEqual, 4)
+
+	fromFiles, err := SolveHandEye(samples)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fromFiles.Camera.Point().Sub(handEyeTestCamera.Point()).Norm(), test.ShouldBeLessThan, 5)
+	test.That(t, spatialmath.OrientationAlmostEqualEps(fromFiles.Camera.Orientation(), handEyeTestCamera.Orientation(), .01), test.ShouldBeTrue)
+
+	// a second run in the same directory can't be told apart from the first
+	err = os.WriteFile(filepath.Join(dir, "another_run_"+handEyeSamplePrefix+"0.json"), []byte("{}"), 0o600)
+	test.That(t, err, test.ShouldBeNil)
+	_, _, err = ReadHandEyeSamples(dir, board)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = res.DoCommand(ctx, map[string]interface{}{"foo": true})
+	test.That(t, err, test.ShouldNotBeNil)
+}
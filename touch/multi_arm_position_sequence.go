@@ -0,0 +1,209 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/utils"
+)
+
+// sequenceStep is one position in a sequence, and how long to wait there once the arm gets to it.
+// target is copied when the sequence starts so nothing can change where a step goes.
+type sequenceStep struct {
+	position uint32
+	target   NamedArmPosition
+	dwell    time.Duration
+}
+
+// parseSequence turns the run_sequence command into steps.
+// Each step is a position name or index, or {"position": <name or index>, "dwell_seconds": <seconds>}.
+func (maps *MultiArmPositionSwitch) parseSequence(steps []interface{}, cmd map[string]interface{}) ([]sequenceStep, *arm.MoveOptions, error) {
+	if len(steps) == 0 {
+		return nil, nil, fmt.Errorf("run_sequence needs at least one position")
+	}
+
+	dwell := 0.0
+	if d, ok := cmd["dwell_seconds"].(float64); ok {
+		dwell = d
+	}
+
+	maps.mu.Lock()
+	positions := maps.positions
+	maps.mu.Unlock()
+
+	parsed := []sequenceStep{}
+	for i, s := range steps {
+		which := s
+		stepDwell := dwell
+		if m, ok := s.(map[string]interface{}); ok {
+			which = m["position"]
+			if d, ok := m["dwell_seconds"].(float64); ok {
+				stepDwell = d
+			}
+		}
+		idx, err := positionIndex(positions, which)
+		if err != nil {
+			return nil, nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		if stepDwell < 0 {
+			return nil, nil, fmt.Errorf("step %d: dwell_seconds can't be negative", i)
+		}
+		parsed = append(parsed, sequenceStep{
+			position: uint32(idx),
+			target:   positions[idx],
+			dwell:    time.Duration(stepDwell * float64(time.Second)),
+		})
+	}
+
+	maxVel, _ := cmd["max_vel_degs_per_sec"].(float64)
+	maxAcc, _ := cmd["max_acc_degs_per_sec2"].(float64)
+	if maxVel < 0 || maxAcc < 0 {
+		return nil, nil, fmt.Errorf("speed limits can't be negative")
+	}
+	if maxVel == 0 && maxAcc == 0 {
+		return parsed, nil, nil
+	}
+	// motion plans its own trajectories, so the limits only work when moving the arm directly
+	if maps.motion != nil {
+		return nil, nil, fmt.Errorf("speed limits can't be used with a motion service")
+	}
+	return parsed, &arm.MoveOptions{MaxVelRads: utils.DegToRad(maxVel), MaxAccRads: utils.DegToRad(maxAcc)}, nil
+}
+
+// startSequence starts going through the steps in the background, progress is in the status command.
+func (maps *MultiArmPositionSwitch) startSequence(ctx context.Context, steps []interface{}, cmd map[string]interface{}) (map[string]interface{}, error) {
+	parsed, opts, err := maps.parseSequence(steps, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = maps.startRun(ctx, parsed, opts, cmd["continue_on_error"] == true)
+	if err != nil {
+		return nil, err
+	}
+
+	return maps.statusMap(), nil
+}
+
+// startRun starts going to every step's position in the background, unless something is already running.
+func (maps *MultiArmPositionSwitch) startRun(ctx context.Context, steps []sequenceStep, opts *arm.MoveOptions, continueOnError bool) (*armRun, error) {
+	maps.mu.Lock()
+	defer maps.mu.Unlock()
+
+	if maps.run != nil && maps.run.running() {
+		return nil, errors.New("switch is currently executing")
+	}
+
+	maps.runSteps = steps
+	maps.runMove = [2]int{}
+
+	// steps blended into an earlier step's move just wait for it, then each is reached in turn
+	batchEnd, batchErr := -1, error(nil)
+	maps.run = startArmRun(ctx, len(steps), continueOnError, func(ctx context.Context, i int) error {
+		if i > batchEnd {
+			batchEnd = maps.blendEnd(steps, i)
+			maps.mu.Lock()
+			maps.runMove = [2]int{i, batchEnd}
+			maps.mu.Unlock()
+			batchErr = maps.moveThrough(ctx, steps[i:batchEnd+1], opts)
+		}
+		if batchErr != nil {
+			return batchErr
+		}
+		maps.updatePosition(steps[i].position)
+
+		if steps[i].dwell > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(steps[i].dwell):
+			}
+		}
+		return nil
+	}, maps.logger)
+
+	return maps.run, nil
+}
+
+// blendEnd is the last step that goes in the same move as step i.
+// Without a motion service the arm goes through joint positions in one move until a step has to dwell,
+// the motion service plans each step on its own.
+func (maps *MultiArmPositionSwitch) blendEnd(steps []sequenceStep, i int) int {
+	if maps.motion != nil {
+		return i
+	}
+	for i < len(steps)-1 && steps[i].dwell == 0 && !steps[i].target.isCartesian() && !steps[i+1].target.isCartesian() {
+		i++
+	}
+	return i
+}
+
+// moveThrough goes to each step in one move, or just moves to it if there's only one.
+func (maps *MultiArmPositionSwitch) moveThrough(ctx context.Context, steps []sequenceStep, opts *arm.MoveOptions) error {
+	if len(steps) == 1 {
+		return maps.moveTo(ctx, steps[0].target, opts)
+	}
+	joints := [][]referenceframe.Input{}
+	for _, s := range steps {
+		joints = append(joints, s.target.Joints)
+	}
+	return maps.arm.MoveThroughJointPositions(ctx, joints, opts, maps.cfg.Extra)
+}
+
+func (maps *MultiArmPositionSwitch) statusMap() map[string]interface{} {
+	maps.mu.Lock()
+	defer maps.mu.Unlock()
+
+	m := map[string]interface{}{
+		"executing": false,
+		"position":  maps.position,
+	}
+	if maps.run == nil {
+		return m
+	}
+
+	positions := []interface{}{}
+	for _, s := range maps.runSteps {
+		positions = append(positions, s.position)
+	}
+	run := maps.run.status()
+	run["positions"] = positions
+	run["blended"] = maps.motion == nil
+	m["run"] = run
+
+	if maps.run.running() {
+		// a blended move is headed for its last step, through the ones before it
+		m["executing"] = true
+		m["target"] = maps.runSteps[maps.runMove[1]].position
+		m["target_steps"] = []interface{}{maps.runMove[0], maps.runMove[1]}
+	}
+	return m
+}
+
+// stop cancels whatever is running and stops the arm.
+func (maps *MultiArmPositionSwitch) stop(ctx context.Context) (map[string]interface{}, error) {
+	maps.mu.Lock()
+	run := maps.run
+	maps.mu.Unlock()
+
+	err := stopArmRun(ctx, maps.arm, run)
+	if err != nil {
+		return nil, err
+	}
+	return maps.statusMap(), nil
+}
+
+func (maps *MultiArmPositionSwitch) Close(ctx context.Context) error {
+	maps.mu.Lock()
+	run := maps.run
+	maps.mu.Unlock()
+
+	if run != nil {
+		run.stop()
+	}
+	return nil
+}
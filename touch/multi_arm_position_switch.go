@@ -2,19 +2,25 @@ package touch
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
-	"sync/atomic"
 	"time"
 
+	"github.com/golang/geo/r3"
+
 	"go.viam.com/rdk/components/arm"
 	toggleswitch "go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/services/motion"
 	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
 	"go.viam.com/utils/trace"
 
 	"github.com/erh/vmodutils"
@@ -34,13 +40,66 @@ func init() {
 		})
 }
 
+// NamedArmPosition is somewhere the arm can go, either joints or a pose in Frame.
+type NamedArmPosition struct {
+	Name        string                                `json:"name,omitempty"`
+	Joints      []float64                             `json:"joints,omitempty"`
+	Point       *r3.Vector                            `json:"point,omitempty"`
+	Orientation *spatialmath.OrientationVectorDegrees `json:"orientation,omitempty"`
+	// Frame is what Point and Orientation are relative to, defaults to world
+	Frame string `json:"frame,omitempty"`
+}
+
+func (p *NamedArmPosition) isCartesian() bool {
+	return p.Point != nil
+}
+
+func (p *NamedArmPosition) frame() string {
+	if p.Frame != "" {
+		return p.Frame
+	}
+	return referenceframe.World
+}
+
+func (p *NamedArmPosition) poseInFrame() *referenceframe.PoseInFrame {
+	return referenceframe.NewPoseInFrame(p.frame(), spatialmath.NewPose(*p.Point, p.Orientation))
+}
+
+func (p *NamedArmPosition) Validate() error {
+	if len(p.Joints) > 0 && p.Point != nil {
+		return fmt.Errorf("position %s can have joints or a point, not both", p.Name)
+	}
+	if len(p.Joints) == 0 && p.Point == nil {
+		return fmt.Errorf("position %s needs joints or a point", p.Name)
+	}
+	if (p.Point == nil) != (p.Orientation == nil) {
+		return fmt.Errorf("position %s needs both a point and an orientation", p.Name)
+	}
+	if p.Frame != "" && p.Point == nil {
+		return fmt.Errorf("position %s has a frame but no point", p.Name)
+	}
+	return nil
+}
+
 type MultiArmPositionSwitchConfig struct {
-	Arm                          string         `json:"arm,omitempty"`
-	JointsList                   [][]float64    `json:"joints_list,omitempty"`
-	Motion                       string         `json:"motion,omitempty"`
-	VisionServices               []string       `json:"vision_services,omitempty"`
-	Extra                        map[string]any `json:"extra,omitempty"`
-	WriteFilesToCaptureDirectory bool           `json:"write_files_to_capture_directory,omitempty"`
+	Arm        string      `json:"arm,omitempty"`
+	JointsList [][]float64 `json:"joints_list,omitempty"`
+	// Positions can be named and cartesian, JointsList is the old way to do joints only
+	Positions                    []NamedArmPosition `json:"positions,omitempty"`
+	Motion                       string             `json:"motion,omitempty"`
+	VisionServices               []string           `json:"vision_services,omitempty"`
+	Extra                        map[string]any     `json:"extra,omitempty"`
+	WriteFilesToCaptureDirectory bool               `json:"write_files_to_capture_directory,omitempty"`
+	// Async makes SetPosition return once the arm starts moving, the status command says when it's done
+	Async bool `json:"async,omitempty"`
+	// how close the arm has to be to a position for GetPosition to say it's there, all default to 1
+	JointToleranceDegs       float64 `json:"joint_tolerance_degs,omitempty"`
+	ToleranceMM              float64 `json:"tolerance_mm,omitempty"`
+	OrientationToleranceDegs float64 `json:"orientation_tolerance_degs,omitempty"`
+}
+
+func (c *MultiArmPositionSwitchConfig) tolerances() armTolerances {
+	return newArmTolerances(c.JointToleranceDegs, c.ToleranceMM, c.OrientationToleranceDegs)
 }
 
 func (c *MultiArmPositionSwitchConfig) Validate(path string) ([]string, []string, error) {
@@ -61,9 +120,17 @@ func (c *MultiArmPositionSwitchConfig) Validate(path string) ([]string, []string
 
 	reqDeps = append(reqDeps, c.VisionServices...)
 
-	if len(c.JointsList) == 0 {
+	if len(c.JointsList) == 0 && len(c.Positions) == 0 {
 		return nil, nil, ErrMustSpecifyAtLeastOneJointPosition
 	}
+	if len(c.JointsList) > 0 && len(c.Positions) > 0 {
+		return nil, nil, fmt.Errorf("can have joints_list or positions, not both")
+	}
+
+	err := validateNamedArmPositions(c.Positions, c.Motion != "")
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if c.Extra != nil && c.Extra[extraParamsKeyGoalState] != nil {
 		return nil, nil, ErrCannotSpecifyGoalStateInExtra
@@ -72,6 +139,42 @@ func (c *MultiArmPositionSwitchConfig) Validate(path string) ([]string, []string
 	return reqDeps, nil, nil
 }
 
+// positions is Positions, or JointsList as unnamed positions.
+func (c *MultiArmPositionSwitchConfig) positions() []NamedArmPosition {
+	if len(c.Positions) > 0 {
+		return slices.Clone(c.Positions)
+	}
+	positions := []NamedArmPosition{}
+	for _, joints := range c.JointsList {
+		positions = append(positions, NamedArmPosition{Joints: joints})
+	}
+	return positions
+}
+
+func validateNamedArmPositions(positions []NamedArmPosition, hasMotion bool) error {
+	names := map[string]bool{}
+	for _, p := range positions {
+		err := p.Validate()
+		if err != nil {
+			return err
+		}
+		if p.isCartesian() && !hasMotion {
+			return fmt.Errorf("position %s is cartesian, which needs motion", p.Name)
+		}
+		if p.Name == "" {
+			continue
+		}
+		if p.Name == ArmPositionUnknownLabel {
+			return fmt.Errorf("positions can't be named %s", ArmPositionUnknownLabel)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("two positions are named %s", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
+
 func newMultiArmPositionSwitch(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (toggleswitch.Switch, error) {
 	newConf, err := resource.NativeConfig[*MultiArmPositionSwitchConfig](config)
 	if err != nil {
@@ -84,10 +187,14 @@ func newMultiArmPositionSwitch(ctx context.Context, deps resource.Dependencies,
 	}
 
 	maps := &MultiArmPositionSwitch{
-		name:   config.ResourceName(),
-		cfg:    newConf,
-		logger: logger,
-		arm:    arm,
+		name:      config.ResourceName(),
+		cfg:       newConf,
+		logger:    logger,
+		arm:       arm,
+		positions: newConf.positions(),
+	}
+	maps.persist = func(ctx context.Context, attrs utils.AttributeMap) error {
+		return vmodutils.UpdateComponentCloudAttributesFromModuleEnv(ctx, maps.name, attrs, logger)
 	}
 
 	if newConf.Motion != "" {
@@ -115,7 +222,6 @@ func newMultiArmPositionSwitch(ctx context.Context, deps resource.Dependencies,
 
 type MultiArmPositionSwitch struct {
 	resource.AlwaysRebuild
-	resource.TriviallyCloseable
 
 	name   resource.Name
 	cfg    *MultiArmPositionSwitchConfig
@@ -126,12 +232,18 @@ type MultiArmPositionSwitch struct {
 	visionServices []vision.Service
 	fsSvc          framesystem.Service
 
-	// 'mu' protects access to 'position'
-	mu       sync.Mutex
-	position uint32
-
-	// 'executing' ensures only one goToPosition call is active at a time
-	executing atomic.Bool
+	// 'mu' protects access to 'position', 'positions', 'run', 'runSteps', and 'runMove'
+	mu        sync.Mutex
+	position  uint32
+	positions []NamedArmPosition
+	// 'run' is what's moving the arm now, or what moved it last, only one can be running at a time
+	run      *armRun
+	runSteps []sequenceStep
+	// 'runMove' is the first and last step of the move the run is doing, more than one when they're blended
+	runMove [2]int
+
+	// persist saves new attributes to the machine's config, it's swapped out in tests
+	persist func(ctx context.Context, attrs utils.AttributeMap) error
 }
 
 func (maps *MultiArmPositionSwitch) Name() resource.Name {
@@ -139,47 +251,282 @@ func (maps *MultiArmPositionSwitch) Name() resource.Name {
 }
 
 func (maps *MultiArmPositionSwitch) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["positions"] == true {
+		return maps.positionsMap(), nil
+	}
+
+	if name, ok := cmd["save"].(string); ok {
+		frame, _ := cmd["frame"].(string)
+		return maps.saveCurrentPosition(ctx, name, cmd["cartesian"] == true, frame)
+	}
+
+	if which, ok := cmd["delete"]; ok {
+		return maps.editPositions(ctx, func(positions []NamedArmPosition) ([]NamedArmPosition, error) {
+			idx, err := positionIndex(positions, which)
+			if err != nil {
+				return nil, err
+			}
+			if len(positions) == 1 {
+				return nil, fmt.Errorf("cannot delete the last position")
+			}
+			return slices.Delete(positions, idx, idx+1), nil
+		})
+	}
+
+	if steps, ok := cmd["run_sequence"].([]interface{}); ok {
+		return maps.startSequence(ctx, steps, cmd)
+	}
+
+	if cmd["status"] == true {
+		return maps.statusMap(), nil
+	}
+
+	if cmd["stop"] == true {
+		return maps.stop(ctx)
+	}
+
+	if order, ok := cmd["reorder"].([]interface{}); ok {
+		return maps.editPositions(ctx, func(positions []NamedArmPosition) ([]NamedArmPosition, error) {
+			if len(order) != len(positions) {
+				return nil, fmt.Errorf("reorder needs all %d positions, got %d", len(positions), len(order))
+			}
+			seen := map[int]bool{}
+			reordered := []NamedArmPosition{}
+			for _, which := range order {
+				idx, err := positionIndex(positions, which)
+				if err != nil {
+					return nil, err
+				}
+				if seen[idx] {
+					return nil, fmt.Errorf("position %v is in reorder twice", which)
+				}
+				seen[idx] = true
+				reordered = append(reordered, positions[idx])
+			}
+			return reordered, nil
+		})
+	}
+
 	return nil, resource.ErrDoUnimplemented
 }
 
-func (maps *MultiArmPositionSwitch) updatePosition(position uint32) {
+func (maps *MultiArmPositionSwitch) positionsMap() map[string]interface{} {
 	maps.mu.Lock()
 	defer maps.mu.Unlock()
-	maps.position = position
+
+	list := []interface{}{}
+	for _, p := range maps.positions {
+		m := map[string]interface{}{"name": p.Name}
+		if p.isCartesian() {
+			m["point"] = *p.Point
+			m["orientation"] = *p.Orientation
+			m["frame"] = p.frame()
+		} else {
+			m["joints"] = p.Joints
+		}
+		list = append(list, m)
+	}
+	return map[string]interface{}{"positions": list}
 }
 
-func (maps *MultiArmPositionSwitch) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
-	if position > uint32(len(maps.cfg.JointsList))-1 {
-		return fmt.Errorf("requested position %d is greater than highest possible position %d", position, len(maps.cfg.JointsList)-1)
+// positionIndex finds which, a name or an index, in positions.
+func positionIndex(positions []NamedArmPosition, which interface{}) (int, error) {
+	switch w := which.(type) {
+	case string:
+		for i, p := range positions {
+			if p.Name == w {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no position named %s", w)
+	case float64:
+		idx := int(w)
+		if float64(idx) != w || idx < 0 || idx >= len(positions) {
+			return 0, fmt.Errorf("bad position index %v", w)
+		}
+		return idx, nil
+	}
+	return 0, fmt.Errorf("position has to be a name or an index, not %T", which)
+}
+
+// saveCurrentPosition puts where the arm is now in name, replacing it if it exists or adding it to the end.
+// Cartesian positions are saved relative to frame, or world if it's empty.
+func (maps *MultiArmPositionSwitch) saveCurrentPosition(ctx context.Context, name string, cartesian bool, frame string) (map[string]interface{}, error) {
+	if name == "" {
+		return nil, fmt.Errorf("need a name to save a position")
+	}
+
+	np := NamedArmPosition{Name: name}
+	if cartesian {
+		np.Frame = frame
+		p, err := maps.fsSvc.GetPose(ctx, maps.cfg.Arm, np.frame(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		point := p.Pose().Point()
+		np.Point = &point
+		np.Orientation = p.Pose().Orientation().OrientationVectorDegrees()
+	} else {
+		inputs, err := maps.arm.JointPositions(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		np.Joints = inputs
+	}
+
+	return maps.editPositions(ctx, func(positions []NamedArmPosition) ([]NamedArmPosition, error) {
+		idx, err := positionIndex(positions, name)
+		if err != nil {
+			return append(positions, np), nil
+		}
+		positions[idx] = np
+		return positions, nil
+	})
+}
+
+// editPositions changes the positions with edit and saves them to the machine's config.
+func (maps *MultiArmPositionSwitch) editPositions(
+	ctx context.Context,
+	edit func([]NamedArmPosition) ([]NamedArmPosition, error),
+) (map[string]interface{}, error) {
+	maps.mu.Lock()
+	if maps.run != nil && maps.run.running() {
+		maps.mu.Unlock()
+		// the run is going through positions by index
+		return nil, errors.New("switch is currently executing")
+	}
+	positions, err := edit(slices.Clone(maps.positions))
+	maps.mu.Unlock()
+	if err != nil {
+		return nil, err
 	}
 
-	err := maps.goToPosition(ctx, position)
+	err = validateNamedArmPositions(positions, maps.motion != nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	newConfig := *maps.cfg
+	newConfig.JointsList = nil
+	newConfig.Positions = positions
+
+	data, err := json.Marshal(&newConfig)
+	if err != nil {
+		return nil, err
+	}
+	attrs := utils.AttributeMap{}
+	err = json.Unmarshal(data, &attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = maps.persist(ctx, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	maps.mu.Lock()
+	maps.positions = positions
+	maps.mu.Unlock()
+
+	return maps.positionsMap(), nil
 }
 
-func (maps *MultiArmPositionSwitch) GetPosition(ctx context.Context, extra map[string]interface{}) (uint32, error) {
+func (maps *MultiArmPositionSwitch) updatePosition(position uint32) {
+	maps.mu.Lock()
+	defer maps.mu.Unlock()
+	maps.position = position
+}
+
+func (maps *MultiArmPositionSwitch) positionAt(position uint32) (NamedArmPosition, error) {
 	maps.mu.Lock()
 	defer maps.mu.Unlock()
-	return maps.position, nil
+	if int(position) >= len(maps.positions) {
+		return NamedArmPosition{}, fmt.Errorf("requested position %d is greater than highest possible position %d", position, len(maps.positions)-1)
+	}
+	return maps.positions[position], nil
+}
+
+func (maps *MultiArmPositionSwitch) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
+	return maps.goToPosition(ctx, position, shouldWait(maps.cfg.Async, extra))
+}
+
+// GetPosition checks where the arm is now, it's the unknown position after the last one if that isn't one of them.
+func (maps *MultiArmPositionSwitch) GetPosition(ctx context.Context, extra map[string]interface{}) (uint32, error) {
+	maps.mu.Lock()
+	positions := maps.positions
+	last := maps.position
+	maps.mu.Unlock()
+
+	tol := maps.cfg.tolerances()
+	var joints []referenceframe.Input
+	poses := map[string]spatialmath.Pose{}
+	unknown := uint32(len(positions))
+	found := unknown
+	for i, p := range positions {
+		at := false
+		if p.isCartesian() {
+			pose, ok := poses[p.frame()]
+			if !ok {
+				current, err := maps.fsSvc.GetPose(ctx, maps.cfg.Arm, p.frame(), nil, nil)
+				if err != nil {
+					return 0, err
+				}
+				pose = current.Pose()
+				poses[p.frame()] = pose
+			}
+			at = tol.poseMatches(pose, p.poseInFrame().Pose())
+		} else {
+			if joints == nil {
+				var err error
+				joints, err = maps.arm.JointPositions(ctx, nil)
+				if err != nil {
+					return 0, err
+				}
+			}
+			at = tol.jointsMatch(joints, p.Joints)
+		}
+		if !at {
+			continue
+		}
+		// positions can be close together, so the last one reached wins
+		if uint32(i) == last {
+			return last, nil
+		}
+		if found == unknown {
+			found = uint32(i)
+		}
+	}
+	return found, nil
 }
 
 func (maps *MultiArmPositionSwitch) GetNumberOfPositions(ctx context.Context, extra map[string]interface{}) (uint32, []string, error) {
+	maps.mu.Lock()
+	defer maps.mu.Unlock()
+
 	var positionStrs []string
-	for i := range maps.cfg.JointsList {
-		positionStrs = append(positionStrs, fmt.Sprintf("go to %d", i))
+	for i, p := range maps.positions {
+		if p.Name != "" {
+			positionStrs = append(positionStrs, p.Name)
+		} else {
+			positionStrs = append(positionStrs, fmt.Sprintf("go to %d", i))
+		}
 	}
-	return uint32(len(maps.cfg.JointsList)), positionStrs, nil
+	positionStrs = append(positionStrs, ArmPositionUnknownLabel)
+	return uint32(len(positionStrs)), positionStrs, nil
 }
 
-func (maps *MultiArmPositionSwitch) goToPosition(ctx context.Context, position uint32) error {
-	if !maps.executing.CompareAndSwap(false, true) {
-		return errors.New("switch is currently executing")
+func (maps *MultiArmPositionSwitch) goToPosition(ctx context.Context, position uint32, wait bool) error {
+	target, err := maps.positionAt(position)
+	if err != nil {
+		return err
 	}
-	defer maps.executing.Store(false)
+
+	run, err := maps.startRun(ctx, []sequenceStep{{position: position, target: target}}, nil, false)
+	if err != nil {
+		return err
+	}
+
 	if maps.cfg.WriteFilesToCaptureDirectory {
 		traceID := ""
 		if span := trace.FromContext(ctx); span != nil {
@@ -195,12 +542,23 @@ func (maps *MultiArmPositionSwitch) goToPosition(ctx context.Context, position u
 		file_utils.SaveJsonFile(maps.cfg, dirPath, fileName, time.Now())
 	}
 
-	maps.updatePosition(position)
-
-	joints := maps.cfg.JointsList[position]
+	if !wait {
+		return nil
+	}
+	return run.wait(ctx)
+}
 
+// moveTo moves the arm to target, it's only called from the running run.
+// opts limits the arm's speed, it's only used when moving the arm directly.
+func (maps *MultiArmPositionSwitch) moveTo(ctx context.Context, target NamedArmPosition, opts *arm.MoveOptions) error {
+	if target.isCartesian() {
+		return goToPositionUsingCartesianMotion(ctx, target.poseInFrame(), maps.motion, maps.visionServices, maps.fsSvc, maps.arm.Name().Name, maps.cfg.Extra, maps.logger)
+	}
 	if maps.motion != nil {
-		return goToPositionUsingJointToJointMotion(ctx, joints, maps.arm.Name().Name, maps.motion, maps.visionServices, maps.cfg.Extra, maps.logger)
+		return goToPositionUsingJointToJointMotion(ctx, target.Joints, maps.arm.Name().Name, maps.motion, maps.visionServices, maps.cfg.Extra, maps.logger)
+	}
+	if opts != nil {
+		return maps.arm.MoveThroughJointPositions(ctx, [][]referenceframe.Input{target.Joints}, opts, maps.cfg.Extra)
 	}
-	return goToPositionUsingMoveToJointPositions(ctx, joints, maps.arm, maps.cfg.Extra, maps.logger)
+	return goToPositionUsingMoveToJointPositions(ctx, target.Joints, maps.arm, maps.cfg.Extra, maps.logger)
 }
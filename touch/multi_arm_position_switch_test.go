@@ -3,15 +3,22 @@ package touch
 import (
 	"context"
 	"errors"
+	"math"
 	"testing"
 
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/arm"
 	toggleswitch "go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
 	"go.viam.com/rdk/testutils/inject"
 	injectMotion "go.viam.com/rdk/testutils/inject/motion"
+	"go.viam.com/rdk/utils"
 	"go.viam.com/rdk/vision"
 	"go.viam.com/test"
 
@@ -105,10 +112,17 @@ func TestMultiArmPositionSwitchSetPositionAndGetPosition(t *testing.T) {
 			return nil
 		}
 
+		var at []float64
+		fakeArm.JointPositionsFunc = func(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+			return at, nil
+		}
+
 		fakeMotion := injectMotion.NewMotionService("builtin")
 		fakeMotionMoveCallCount := 0
 		fakeMotion.MoveFunc = func(ctx context.Context, req motion.MoveReq) (bool, error) {
 			fakeMotionMoveCallCount++
+			goal := req.Extra[extraParamsKeyGoalState].(map[string]any)["configuration"].(map[string]any)
+			at = goal["arm"].([]float64)
 			return true, nil
 		}
 
@@ -182,7 +196,7 @@ func TestMultiArmPositionSwitchSetPositionAndGetPosition(t *testing.T) {
 		test.That(t, err, test.ShouldBeNil)
 		test.That(t, position, test.ShouldEqual, 1)
 
-		// Now make SetPosition fail and confirm GetPosition still shows the attempt to set a different position
+		// Now make SetPosition fail and confirm GetPosition still shows the last position the arm got to
 		fakeMotion.MoveFunc = func(ctx context.Context, req motion.MoveReq) (bool, error) {
 			fakeMotionMoveCallCount++
 			return false, dummyErr
@@ -195,11 +209,15 @@ func TestMultiArmPositionSwitchSetPositionAndGetPosition(t *testing.T) {
 		test.That(t, fakeVision2GetObjectPointCloudsCallCount, test.ShouldEqual, 3)
 		position, err = s.GetPosition(ctx, nil)
 		test.That(t, err, test.ShouldBeNil)
-		test.That(t, position, test.ShouldEqual, 0)
+		test.That(t, position, test.ShouldEqual, 1)
 	})
 
 	t.Run("SetPosition uses only arm.MoveToJointPositions when motion service is not configured", func(t *testing.T) {
 		fakeArm := inject.NewArm("arm")
+		var at []float64
+		fakeArm.JointPositionsFunc = func(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+			return at, nil
+		}
 		fakeArmMoveToJointPositionsCallCount := 0
 		fakeArm.MoveToJointPositionsFunc = func(ctx context.Context, joints []float64, extra map[string]any) error {
 			fakeArmMoveToJointPositionsCallCount++
@@ -209,6 +227,7 @@ func TestMultiArmPositionSwitchSetPositionAndGetPosition(t *testing.T) {
 			} else if fakeArmMoveToJointPositionsCallCount == 2 {
 				test.That(t, joints, test.ShouldResemble, []float64{1.0, 1.0, 1.0})
 			}
+			at = joints
 			return nil
 		}
 
@@ -257,7 +276,7 @@ func TestMultiArmPositionSwitchSetPositionAndGetPosition(t *testing.T) {
 		test.That(t, err, test.ShouldBeNil)
 		test.That(t, position, test.ShouldEqual, 1)
 
-		// Now make SetPosition fail and confirm GetPosition still shows the attempt to set a different position
+		// Now make SetPosition fail and confirm GetPosition still shows the last position the arm got to
 		fakeArm.MoveToJointPositionsFunc = func(ctx context.Context, joints []float64, extra map[string]any) error {
 			fakeArmMoveToJointPositionsCallCount++
 			return dummyErr
@@ -267,6 +286,488 @@ func TestMultiArmPositionSwitchSetPositionAndGetPosition(t *testing.T) {
 		test.That(t, fakeArmMoveToJointPositionsCallCount, test.ShouldEqual, 3)
 		position, err = s.GetPosition(ctx, nil)
 		test.That(t, err, test.ShouldBeNil)
+		test.That(t, position, test.ShouldEqual, 1)
+	})
+}
+
+func TestMultiArmPositionSwitchNamedPositions(t *testing.T) {
+	ctx := context.Background()
+
+	current := []float64{5, 5, 5}
+	fakeArm := inject.NewArm("arm")
+	fakeArm.JointPositionsFunc = func(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+		return current, nil
+	}
+	moved := [][]float64{}
+	fakeArm.MoveToJointPositionsFunc = func(ctx context.Context, joints []float64, extra map[string]any) error {
+		moved = append(moved, joints)
+		return nil
+	}
+
+	fakeMotion := injectMotion.NewMotionService("builtin")
+	destinations := []*referenceframe.PoseInFrame{}
+	fakeMotion.MoveFunc = func(ctx context.Context, req motion.MoveReq) (bool, error) {
+		destinations = append(destinations, req.Destination)
+		return true, nil
+	}
+
+	fakeFsSvc := inject.NewFrameSystemService(framesystem.PublicServiceName.Name)
+	fakeFsSvc.GetPoseFunc = func(
+		ctx context.Context,
+		componentName, destinationFrame string,
+		supplementalTransforms []*referenceframe.LinkInFrame,
+		extra map[string]interface{},
+	) (*referenceframe.PoseInFrame, error) {
+		return referenceframe.NewPoseInFrame(destinationFrame, spatialmath.NewPoseFromPoint(r3.Vector{X: 1})), nil
+	}
+
+	newSwitch := func(cfg *MultiArmPositionSwitchConfig) (*MultiArmPositionSwitch, *utils.AttributeMap) {
+		_, _, err := cfg.Validate("")
+		test.That(t, err, test.ShouldBeNil)
+
+		res, err := newMultiArmPositionSwitch(ctx, resource.Dependencies{
+			fakeArm.Name():    fakeArm,
+			fakeMotion.Name(): fakeMotion,
+			fakeFsSvc.Name():  fakeFsSvc,
+		}, resource.Config{
+			Name:                "multi_arm_position_switch",
+			API:                 toggleswitch.API,
+			Model:               resource.Model{Family: vmodutils.NamespaceFamily},
+			ConvertedAttributes: cfg,
+		}, logging.NewTestLogger(t))
+		test.That(t, err, test.ShouldBeNil)
+
+		s := res.(*MultiArmPositionSwitch)
+		saved := &utils.AttributeMap{}
+		s.persist = func(ctx context.Context, attrs utils.AttributeMap) error {
+			*saved = attrs
+			return nil
+		}
+		return s, saved
+	}
+
+	t.Run("named and cartesian positions", func(t *testing.T) {
+		s, _ := newSwitch(&MultiArmPositionSwitchConfig{
+			Arm:    "arm",
+			Motion: "builtin",
+			Positions: []NamedArmPosition{
+				{Name: "home", Joints: []float64{0, 0, 0}},
+				{Point: &r3.Vector{X: 100}, Orientation: &spatialmath.OrientationVectorDegrees{OZ: -1}},
+				{Point: &r3.Vector{X: 1}, Orientation: &spatialmath.OrientationVectorDegrees{OZ: 1}, Frame: "table"},
+				{Point: &r3.Vector{X: 50}, Orientation: &spatialmath.OrientationVectorDegrees{OZ: 1}, Frame: "table"},
+			},
+		})
+
+		n, labels, err := s.GetNumberOfPositions(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, n, test.ShouldEqual, 5)
+		test.That(t, labels, test.ShouldResemble, []string{"home", "go to 1", "go to 2", "go to 3", "unknown"})
+
+		err = s.SetPosition(ctx, 1, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(destinations), test.ShouldEqual, 1)
+		test.That(t, destinations[0].Parent(), test.ShouldEqual, referenceframe.World)
+		test.That(t, destinations[0].Pose().Point(), test.ShouldResemble, r3.Vector{X: 100})
+
+		// already there, so it doesn't move
+		err = s.SetPosition(ctx, 2, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(destinations), test.ShouldEqual, 1)
+
+		err = s.SetPosition(ctx, 3, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(destinations), test.ShouldEqual, 2)
+		test.That(t, destinations[1].Parent(), test.ShouldEqual, "table")
+
+		out, err := s.DoCommand(ctx, map[string]interface{}{"save": "here", "cartesian": true, "frame": "table"})
+		test.That(t, err, test.ShouldBeNil)
+		positions := out["positions"].([]interface{})
+		test.That(t, positions[4].(map[string]interface{})["frame"], test.ShouldEqual, "table")
+		test.That(t, positions[4].(map[string]interface{})["point"], test.ShouldResemble, r3.Vector{X: 1})
+	})
+
+	t.Run("save, delete, and reorder", func(t *testing.T) {
+		s, saved := newSwitch(&MultiArmPositionSwitchConfig{
+			Arm:        "arm",
+			JointsList: [][]float64{{0, 0, 0}, {1, 1, 1}},
+			Extra:      map[string]any{"foo": "bar"},
+		})
+
+		_, err := s.DoCommand(ctx, map[string]interface{}{"save": "above_bin"})
+		test.That(t, err, test.ShouldBeNil)
+		_, labels, err := s.GetNumberOfPositions(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, labels, test.ShouldResemble, []string{"go to 0", "go to 1", "above_bin", "unknown"})
+		test.That(t, (*saved)["extra"], test.ShouldResemble, map[string]interface{}{"foo": "bar"})
+		test.That(t, (*saved)["joints_list"], test.ShouldBeNil)
+		test.That(t, len((*saved)["positions"].([]interface{})), test.ShouldEqual, 3)
+
+		err = s.SetPosition(ctx, 2, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, moved[len(moved)-1], test.ShouldResemble, []float64{5, 5, 5})
+
+		// saving over an existing name replaces it
+		current = []float64{6, 6, 6}
+		_, err = s.DoCommand(ctx, map[string]interface{}{"save": "above_bin"})
+		test.That(t, err, test.ShouldBeNil)
+		n, _, err := s.GetNumberOfPositions(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, n, test.ShouldEqual, 4)
+
+		out, err := s.DoCommand(ctx, map[string]interface{}{"reorder": []interface{}{"above_bin", 1.0, 0.0}})
+		test.That(t, err, test.ShouldBeNil)
+		positions := out["positions"].([]interface{})
+		test.That(t, positions[0].(map[string]interface{})["joints"], test.ShouldResemble, []float64{6, 6, 6})
+		test.That(t, positions[1].(map[string]interface{})["joints"], test.ShouldResemble, []float64{1, 1, 1})
+
+		_, err = s.DoCommand(ctx, map[string]interface{}{"delete": 1.0})
+		test.That(t, err, test.ShouldBeNil)
+		_, labels, err = s.GetNumberOfPositions(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, labels, test.ShouldResemble, []string{"above_bin", "go to 1", "unknown"})
+
+		// cartesian needs motion
+		_, err = s.DoCommand(ctx, map[string]interface{}{"save": "pose", "cartesian": true})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		_, err = s.DoCommand(ctx, map[string]interface{}{"reorder": []interface{}{0.0, 0.0}})
+		test.That(t, err, test.ShouldNotBeNil)
+		_, err = s.DoCommand(ctx, map[string]interface{}{"delete": "nope"})
+		test.That(t, err, test.ShouldNotBeNil)
+		_, err = s.DoCommand(ctx, map[string]interface{}{"save": "unknown"})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		// if saving fails nothing changes
+		s.persist = func(ctx context.Context, attrs utils.AttributeMap) error {
+			return dummyErr
+		}
+		_, err = s.DoCommand(ctx, map[string]interface{}{"delete": 0.0})
+		test.That(t, err, test.ShouldBeError, dummyErr)
+		n, _, err = s.GetNumberOfPositions(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, n, test.ShouldEqual, 3)
+	})
+
+	t.Run("GetPosition is where the arm is", func(t *testing.T) {
+		s, _ := newSwitch(&MultiArmPositionSwitchConfig{
+			Arm:                "arm",
+			Motion:             "builtin",
+			JointToleranceDegs: 2,
+			Positions: []NamedArmPosition{
+				{Name: "home", Joints: []float64{0, 0, 0}},
+				{Name: "also_home", Joints: []float64{0, 0, .01}},
+				{Name: "table", Point: &r3.Vector{X: 1.5}, Orientation: &spatialmath.OrientationVectorDegrees{OZ: 1}, Frame: "table"},
+			},
+		})
+
+		current = []float64{0, .02, 0}
+		position, err := s.GetPosition(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, position, test.ShouldEqual, 0)
+
+		// jogged away from home, but still within 1mm of table
+		current = []float64{0, .1, 0}
+		position, err = s.GetPosition(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, position, test.ShouldEqual, 2)
+
+		s.cfg.ToleranceMM = .1
+		position, err = s.GetPosition(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, position, test.ShouldEqual, 3)
+		_, labels, err := s.GetNumberOfPositions(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, labels[position], test.ShouldEqual, ArmPositionUnknownLabel)
+		test.That(t, s.SetPosition(ctx, position, nil), test.ShouldNotBeNil)
+		// so scans leave it out
+		n, err := settablePositions(ctx, s)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, n, test.ShouldEqual, 3)
+
+		// at both home and also_home, so it's the one it went to
+		current = []float64{0, 0, 0}
+		position, err = s.GetPosition(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
 		test.That(t, position, test.ShouldEqual, 0)
+		err = s.SetPosition(ctx, 1, nil)
+		test.That(t, err, test.ShouldBeNil)
+		position, err = s.GetPosition(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, position, test.ShouldEqual, 1)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		bad := []*MultiArmPositionSwitchConfig{
+			{Arm: "arm", JointsList: [][]float64{{0}}, Positions: []NamedArmPosition{{Joints: []float64{0}}}},
+			{Arm: "arm", Positions: []NamedArmPosition{{Name: "a", Joints: []float64{0}}, {Name: "a", Joints: []float64{1}}}},
+			{Arm: "arm", Positions: []NamedArmPosition{{Point: &r3.Vector{}, Orientation: &spatialmath.OrientationVectorDegrees{OZ: 1}}}},
+			{Arm: "arm", Motion: "builtin", Positions: []NamedArmPosition{{Point: &r3.Vector{}}}},
+			{Arm: "arm", Positions: []NamedArmPosition{{Name: "empty"}}},
+			{Arm: "arm", Positions: []NamedArmPosition{{Joints: []float64{0}, Frame: "table"}}},
+		}
+		for _, cfg := range bad {
+			_, _, err := cfg.Validate("")
+			test.That(t, err, test.ShouldNotBeNil)
+		}
+	})
+}
+
+func TestMultiArmPositionSwitchSequence(t *testing.T) {
+	ctx := context.Background()
+
+	moved := [][]float64{}
+	moves := 0
+	var moveOpts *arm.MoveOptions
+	var duringMove func()
+	fakeArm := inject.NewArm("arm")
+	fakeArm.MoveToJointPositionsFunc = func(ctx context.Context, joints []float64, extra map[string]any) error {
+		if joints[0] == 2 {
+			return dummyErr
+		}
+		moved = append(moved, joints)
+		moves++
+		return nil
+	}
+	fakeArm.MoveThroughJointPositionsFunc = func(
+		ctx context.Context, positions [][]referenceframe.Input, options *arm.MoveOptions, extra map[string]interface{},
+	) error {
+		for _, p := range positions {
+			if p[0] == 2 {
+				return dummyErr
+			}
+		}
+		if duringMove != nil {
+			duringMove()
+		}
+		moved = append(moved, positions...)
+		moves++
+		moveOpts = options
+		return nil
+	}
+	fakeArm.JointPositionsFunc = func(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+		return moved[len(moved)-1], nil
+	}
+	fakeArm.StopFunc = func(ctx context.Context, extra map[string]interface{}) error {
+		return nil
+	}
+	fakeFsSvc := inject.NewFrameSystemService(framesystem.PublicServiceName.Name)
+
+	cfg := &MultiArmPositionSwitchConfig{
+		Arm: "arm",
+		Positions: []NamedArmPosition{
+			{Name: "home", Joints: []float64{0, 0, 0}},
+			{Name: "bin", Joints: []float64{1, 1, 1}},
+			{Name: "broken", Joints: []float64{2, 2, 2}},
+		},
+	}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	res, err := newMultiArmPositionSwitch(ctx, resource.Dependencies{
+		fakeArm.Name():   fakeArm,
+		fakeFsSvc.Name(): fakeFsSvc,
+	}, resource.Config{
+		Name:                "multi_arm_position_switch",
+		API:                 toggleswitch.API,
+		Model:               resource.Model{Family: vmodutils.NamespaceFamily},
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	s := res.(*MultiArmPositionSwitch)
+	defer s.Close(ctx)
+
+	run := func(cmd map[string]interface{}) map[string]interface{} {
+		_, err := s.DoCommand(ctx, cmd)
+		test.That(t, err, test.ShouldBeNil)
+		<-s.run.finished
+		status, err := s.DoCommand(ctx, map[string]interface{}{"status": true})
+		test.That(t, err, test.ShouldBeNil)
+		return status["run"].(map[string]interface{})
+	}
+
+	t.Run("runs every step", func(t *testing.T) {
+		moved = nil
+		moves = 0
+		seq := run(map[string]interface{}{"run_sequence": []interface{}{"bin", 0.0, map[string]interface{}{"position": "bin"}}})
+		test.That(t, seq["running"], test.ShouldBeFalse)
+		test.That(t, seq["completed"], test.ShouldEqual, 3)
+		test.That(t, seq["errors"], test.ShouldBeEmpty)
+		test.That(t, seq["blended"], test.ShouldBeTrue)
+		test.That(t, moved, test.ShouldResemble, [][]float64{{1, 1, 1}, {0, 0, 0}, {1, 1, 1}})
+		// there's no motion service, so it's all one move
+		test.That(t, moves, test.ShouldEqual, 1)
+	})
+
+	t.Run("a blended move targets its last step", func(t *testing.T) {
+		var during map[string]interface{}
+		duringMove = func() {
+			during = s.statusMap()
+		}
+		defer func() { duringMove = nil }()
+
+		run(map[string]interface{}{"run_sequence": []interface{}{"home", "bin", "home", "bin"}})
+		test.That(t, during["executing"], test.ShouldBeTrue)
+		test.That(t, during["target"], test.ShouldEqual, 1)
+		test.That(t, during["target_steps"], test.ShouldResemble, []interface{}{0, 3})
+
+		position, err := s.GetPosition(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, position, test.ShouldEqual, 1)
 	})
+
+	t.Run("speed limits go to the arm", func(t *testing.T) {
+		moved = nil
+		run(map[string]interface{}{"run_sequence": []interface{}{"home"}, "max_vel_degs_per_sec": 90.0})
+		test.That(t, moved, test.ShouldResemble, [][]float64{{0, 0, 0}})
+		test.That(t, moveOpts.MaxVelRads, test.ShouldAlmostEqual, math.Pi/2)
+	})
+
+	t.Run("only blends until a dwell", func(t *testing.T) {
+		moved = nil
+		moves = 0
+		run(map[string]interface{}{"run_sequence": []interface{}{
+			"bin", map[string]interface{}{"position": "home", "dwell_seconds": .01}, "bin", "home",
+		}})
+		test.That(t, moved, test.ShouldResemble, [][]float64{{1, 1, 1}, {0, 0, 0}, {1, 1, 1}, {0, 0, 0}})
+		test.That(t, moves, test.ShouldEqual, 2)
+	})
+
+	t.Run("aborts on errors unless told to continue", func(t *testing.T) {
+		// each step dwells so they're separate moves
+		steps := []interface{}{"home", "broken", "bin"}
+		moved = nil
+		seq := run(map[string]interface{}{"run_sequence": steps, "dwell_seconds": .01})
+		test.That(t, seq["completed"], test.ShouldEqual, 2)
+		test.That(t, seq["error"], test.ShouldContainSubstring, "step 1")
+		test.That(t, len(moved), test.ShouldEqual, 1)
+
+		moved = nil
+		seq = run(map[string]interface{}{"run_sequence": steps, "dwell_seconds": .01, "continue_on_error": true})
+		test.That(t, seq["completed"], test.ShouldEqual, 3)
+		test.That(t, seq["error"], test.ShouldBeNil)
+		test.That(t, seq["errors"], test.ShouldContainKey, "1")
+		test.That(t, len(moved), test.ShouldEqual, 2)
+
+		// when a blended move fails every step in it failed
+		moved = nil
+		seq = run(map[string]interface{}{"run_sequence": steps, "continue_on_error": true})
+		test.That(t, seq["completed"], test.ShouldEqual, 3)
+		test.That(t, seq["errors"], test.ShouldContainKey, "0")
+		test.That(t, seq["errors"], test.ShouldContainKey, "2")
+		test.That(t, len(moved), test.ShouldEqual, 0)
+	})
+
+	t.Run("stop and busy", func(t *testing.T) {
+		_, err := s.DoCommand(ctx, map[string]interface{}{"run_sequence": []interface{}{"home", "bin"}, "dwell_seconds": 10.0})
+		test.That(t, err, test.ShouldBeNil)
+
+		_, err = s.DoCommand(ctx, map[string]interface{}{"run_sequence": []interface{}{"home"}})
+		test.That(t, err, test.ShouldNotBeNil)
+		err = s.SetPosition(ctx, 0, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		// editing would move positions out from under the run
+		_, err = s.DoCommand(ctx, map[string]interface{}{"delete": "home"})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		status, err := s.DoCommand(ctx, map[string]interface{}{"stop": true})
+		test.That(t, err, test.ShouldBeNil)
+		seq := status["run"].(map[string]interface{})
+		test.That(t, seq["running"], test.ShouldBeFalse)
+		test.That(t, seq["error"], test.ShouldEqual, "stopped")
+		test.That(t, status["executing"], test.ShouldBeFalse)
+	})
+
+	t.Run("bad sequences", func(t *testing.T) {
+		for _, cmd := range []map[string]interface{}{
+			{"run_sequence": []interface{}{}},
+			{"run_sequence": []interface{}{"nope"}},
+			{"run_sequence": []interface{}{5.0}},
+			{"run_sequence": []interface{}{"home"}, "dwell_seconds": -1.0},
+		} {
+			_, err := s.DoCommand(ctx, cmd)
+			test.That(t, err, test.ShouldNotBeNil)
+		}
+
+		_, err := s.DoCommand(ctx, map[string]interface{}{"foo": true})
+		test.That(t, errors.Is(err, resource.ErrDoUnimplemented), test.ShouldBeTrue)
+	})
+}
+
+func TestMultiArmPositionSwitchAsync(t *testing.T) {
+	ctx := context.Background()
+
+	// moves don't finish until they're let go or canceled
+	letGo := make(chan struct{})
+	at := []float64{0, 0, 0}
+	fakeArm := inject.NewArm("arm")
+	fakeArm.MoveToJointPositionsFunc = func(ctx context.Context, joints []float64, extra map[string]any) error {
+		select {
+		case <-letGo:
+			at = joints
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	fakeArm.JointPositionsFunc = func(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+		return at, nil
+	}
+	stopped := 0
+	fakeArm.StopFunc = func(ctx context.Context, extra map[string]interface{}) error {
+		stopped++
+		return nil
+	}
+	fakeFsSvc := inject.NewFrameSystemService(framesystem.PublicServiceName.Name)
+
+	cfg := &MultiArmPositionSwitchConfig{
+		Arm:        "arm",
+		JointsList: [][]float64{{0, 0, 0}, {1, 1, 1}},
+		Async:      true,
+	}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	res, err := newMultiArmPositionSwitch(ctx, resource.Dependencies{
+		fakeArm.Name():   fakeArm,
+		fakeFsSvc.Name(): fakeFsSvc,
+	}, resource.Config{
+		Name:                "multi_arm_position_switch",
+		API:                 toggleswitch.API,
+		Model:               resource.Model{Family: vmodutils.NamespaceFamily},
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	s := res.(*MultiArmPositionSwitch)
+	defer s.Close(ctx)
+
+	err = s.SetPosition(ctx, 1, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	status, err := s.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status["executing"], test.ShouldBeTrue)
+	test.That(t, status["target"], test.ShouldEqual, 1)
+	test.That(t, status["position"], test.ShouldEqual, 0)
+
+	err = s.SetPosition(ctx, 0, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	status, err = s.DoCommand(ctx, map[string]interface{}{"stop": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, stopped, test.ShouldEqual, 1)
+	test.That(t, status["executing"], test.ShouldBeFalse)
+	test.That(t, status["run"].(map[string]interface{})["error"], test.ShouldEqual, "stopped")
+
+	// it never got there
+	position, err := s.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, position, test.ShouldEqual, 0)
+
+	// wait makes it block anyway
+	close(letGo)
+	err = s.SetPosition(ctx, 1, map[string]interface{}{"wait": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.run.running(), test.ShouldBeFalse)
+
+	position, err = s.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, position, test.ShouldEqual, 1)
 }
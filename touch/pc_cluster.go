@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"image"
 	"math"
+	"sort"
+	"time"
 
 	"github.com/golang/geo/r3"
 
@@ -32,120 +34,275 @@ func init() {
 		})
 }
 
-func within(a, b pointcloud.PointCloud, within float64) bool {
-	amd := a.MetaData()
-	bmd := b.MetaData()
+type clusterCell struct {
+	points   []pointcloud.PointAndData
+	min, max r3.Vector
+}
+
+func (c *clusterCell) add(p r3.Vector, d pointcloud.Data) {
+	if len(c.points) == 0 {
+		c.min = p
+		c.max = p
+	} else {
+		c.min = r3.Vector{math.Min(c.min.X, p.X), math.Min(c.min.Y, p.Y), math.Min(c.min.Z, p.Z)}
+		c.max = r3.Vector{math.Max(c.max.X, p.X), math.Max(c.max.Y, p.Y), math.Max(c.max.Z, p.Z)}
+	}
+	c.points = append(c.points, pointcloud.PointAndData{P: p, D: d})
+}
+
+// boxDistance is the closest two points in a and b could possibly be.
+func (c *clusterCell) boxDistance(o *clusterCell) float64 {
+	dx := math.Max(0, math.Max(c.min.X-o.max.X, o.min.X-c.max.X))
+	dy := math.Max(0, math.Max(c.min.Y-o.max.Y, o.min.Y-c.max.Y))
+	dz := math.Max(0, math.Max(c.min.Z-o.max.Z, o.min.Z-c.max.Z))
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
 
-	d := math.Abs(amd.Center().Distance(bmd.Center()))
-	if d > (amd.MaxSideLength() + bmd.MaxSideLength()) {
+// within returns true if any point in c is closer than maxDistance to any point in o.
+func (c *clusterCell) within(o *clusterCell, maxDistance float64) bool {
+	if c.boxDistance(o) >= maxDistance {
 		return false
 	}
 
-	good := false
-	a.Iterate(0, 0, func(ap r3.Vector, _ pointcloud.Data) bool {
-		b.Iterate(0, 0, func(bp r3.Vector, _ pointcloud.Data) bool {
-			d := math.Abs(ap.Distance(bp))
-			if d < within {
-				good = true
-				return false
+	d2 := maxDistance * maxDistance
+	for _, a := range c.points {
+		for _, b := range o.points {
+			if a.P.Sub(b.P).Norm2() < d2 {
+				return true
 			}
-			return true
-		})
-		return true
-	})
+		}
+	}
+	return false
+}
 
-	return good
+type unionFind struct {
+	parent []int
+	rank   []int
 }
 
-func addAll(sink, source pointcloud.PointCloud) error {
-	var err error
-	source.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
-		err = sink.Set(p, d)
-		return err == nil
-	})
-	return err
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{
+		parent: make([]int, n),
+		rank:   make([]int, n),
+	}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+	return uf
 }
 
-func merge(a, b *pointcloud.BasicOctree) (*pointcloud.BasicOctree, error) {
-	x := pointcloud.NewBasicPointCloud(a.Size() + b.Size())
+func (uf *unionFind) find(x int) int {
+	for uf.parent[x] != x {
+		uf.parent[x] = uf.parent[uf.parent[x]]
+		x = uf.parent[x]
+	}
+	return x
+}
 
-	err := addAll(x, a)
-	if err != nil {
-		return nil, err
+func (uf *unionFind) union(a, b int) {
+	a = uf.find(a)
+	b = uf.find(b)
+	if a == b {
+		return
+	}
+	if uf.rank[a] < uf.rank[b] {
+		a, b = b, a
 	}
+	uf.parent[b] = a
+	if uf.rank[a] == uf.rank[b] {
+		uf.rank[a]++
+	}
+}
 
-	err = addAll(x, b)
-	if err != nil {
-		return nil, err
+// neighborOffsets are the 26 cells around a cell.
+var neighborOffsets = func() []voxelKey {
+	offsets := []voxelKey{}
+	for x := int64(-1); x <= 1; x++ {
+		for y := int64(-1); y <= 1; y++ {
+			for z := int64(-1); z <= 1; z++ {
+				if x == 0 && y == 0 && z == 0 {
+					continue
+				}
+				offsets = append(offsets, voxelKey{x, y, z})
+			}
+		}
+	}
+	return offsets
+}()
+
+// groupsToClouds turns groups of points into clouds, dropping any with minPointsPerCluster points or fewer,
+// biggest first.
+func groupsToClouds(groups map[int][]pointcloud.PointAndData, minPointsPerCluster int) ([]pointcloud.PointCloud, error) {
+	clusters := []pointcloud.PointCloud{}
+	for _, g := range groups {
+		if len(g) <= minPointsPerCluster {
+			continue
+		}
+		c := pointcloud.NewBasicPointCloud(len(g))
+		for _, p := range g {
+			err := c.Set(p.P, p.D)
+			if err != nil {
+				return nil, err
+			}
+		}
+		clusters = append(clusters, c)
 	}
 
-	return pointcloud.ToBasicOctree(x, 0)
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Size() > clusters[j].Size()
+	})
+
+	return clusters, nil
 }
 
+// Cluster splits pc into a grid of maxDistance sized cells, throws away cells with fewer than minPointsPerSegment
+// points, and joins neighboring cells that have points closer than maxDistance.
+// Clusters with minPointsPerCluster points or fewer are dropped. Results are biggest first.
 func Cluster(pc pointcloud.PointCloud, maxDistance float64, minPointsPerSegment, minPointsPerCluster int) ([]pointcloud.PointCloud, error) {
+	if maxDistance <= 0 {
+		return nil, fmt.Errorf("maxDistance must be positive, got %v", maxDistance)
+	}
 
-	buckets := map[string]pointcloud.PointCloud{}
+	index := map[voxelKey]int{}
+	cells := []*clusterCell{}
 
 	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
-		b := fmt.Sprintf("%d-%d-%d",
-			int(math.Ceil(p.X/maxDistance)),
-			int(math.Ceil(p.Y/maxDistance)),
-			int(math.Ceil(p.Z/maxDistance)))
-
-		pc, ok := buckets[b]
-		if !ok {
-			pc = pointcloud.NewBasicPointCloud(0)
-			buckets[b] = pc
+		k := voxelKey{
+			int64(math.Ceil(p.X / maxDistance)),
+			int64(math.Ceil(p.Y / maxDistance)),
+			int64(math.Ceil(p.Z / maxDistance)),
 		}
-		err := pc.Set(p, d)
-		if err != nil {
-			panic(err)
+		i, ok := index[k]
+		if !ok {
+			i = len(cells)
+			index[k] = i
+			cells = append(cells, &clusterCell{})
 		}
+		cells[i].add(p, d)
 		return true
 	})
 
-	segments := []*pointcloud.BasicOctree{}
+	dense := func(i int) bool {
+		return minPointsPerSegment <= 0 || len(cells[i].points) >= minPointsPerSegment
+	}
+
+	uf := newUnionFind(len(cells))
 
-	for _, b := range buckets {
-		if minPointsPerSegment > 0 && b.Size() < minPointsPerSegment {
+	for k, i := range index {
+		if !dense(i) {
 			continue
 		}
+		for _, o := range neighborOffsets {
+			j, ok := index[voxelKey{k.x + o.x, k.y + o.y, k.z + o.z}]
+			// each pair only needs to be checked once
+			if !ok || j < i || !dense(j) {
+				continue
+			}
+			if uf.find(i) == uf.find(j) {
+				continue
+			}
+			if cells[i].within(cells[j], maxDistance) {
+				uf.union(i, j)
+			}
+		}
+	}
 
-		o, err := pointcloud.ToBasicOctree(b, 0)
-		if err != nil {
-			return nil, err
+	groups := map[int][]pointcloud.PointAndData{}
+	for i, c := range cells {
+		if !dense(i) {
+			continue
 		}
+		root := uf.find(i)
+		groups[root] = append(groups[root], c.points...)
+	}
+
+	return groupsToClouds(groups, minPointsPerCluster)
+}
 
-		segments = append(segments, o)
+// ClusterDBSCAN is DBSCAN: points with at least minPoints points (including themselves) within eps are core points,
+// core points within eps of each other are in the same cluster, and other points join a cluster if they are within
+// eps of one of its core points. Everything else is noise.
+// Clusters with minPointsPerCluster points or fewer are dropped. Results are biggest first.
+func ClusterDBSCAN(pc pointcloud.PointCloud, eps float64, minPoints, minPointsPerCluster int) ([]pointcloud.PointCloud, error) {
+	if eps <= 0 {
+		return nil, fmt.Errorf("eps must be positive, got %v", eps)
 	}
 
-	for {
-		start := len(segments)
-		for x := 0; x < len(segments); x++ {
-			for y := x + 1; y < len(segments); y++ {
-				if within(segments[x], segments[y], maxDistance) {
-					n, err := merge(segments[x], segments[y])
-					if err != nil {
-						return nil, err
+	points := make([]pointcloud.PointAndData, 0, pc.Size())
+	cells := map[voxelKey][]int{}
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		k := newVoxelKey(p, eps)
+		cells[k] = append(cells[k], len(points))
+		points = append(points, pointcloud.PointAndData{P: p, D: d})
+		return true
+	})
+
+	eps2 := eps * eps
+
+	neighbors := func(i int, fn func(j int) bool) {
+		p := points[i].P
+		k := newVoxelKey(p, eps)
+		for x := int64(-1); x <= 1; x++ {
+			for y := int64(-1); y <= 1; y++ {
+				for z := int64(-1); z <= 1; z++ {
+					for _, j := range cells[voxelKey{k.x + x, k.y + y, k.z + z}] {
+						if p.Sub(points[j].P).Norm2() <= eps2 {
+							if !fn(j) {
+								return
+							}
+						}
 					}
-					segments[x] = n
-					segments = append(segments[0:y], segments[y+1:]...)
 				}
 			}
 		}
-		if len(segments) == start {
-			break
+	}
+
+	core := make([]bool, len(points))
+	for i := range points {
+		count := 0
+		neighbors(i, func(j int) bool {
+			count++
+			return count < minPoints
+		})
+		core[i] = count >= minPoints
+	}
+
+	uf := newUnionFind(len(points))
+	for i := range points {
+		if !core[i] {
+			continue
 		}
+		neighbors(i, func(j int) bool {
+			if j > i && core[j] {
+				uf.union(i, j)
+			}
+			return true
+		})
 	}
 
-	clusters := []pointcloud.PointCloud{}
-	for _, o := range segments {
-		if o.Size() > minPointsPerCluster {
-			clusters = append(clusters, o)
+	groups := map[int][]pointcloud.PointAndData{}
+	for i, p := range points {
+		owner := -1
+		if core[i] {
+			owner = i
+		} else {
+			neighbors(i, func(j int) bool {
+				if core[j] {
+					owner = j
+					return false
+				}
+				return true
+			})
+		}
+		if owner < 0 {
+			continue
 		}
+		root := uf.find(owner)
+		groups[root] = append(groups[root], p)
 	}
 
-	return clusters, nil
+	return groupsToClouds(groups, minPointsPerCluster)
 }
 
 type ClusterConfig struct {
@@ -153,6 +310,28 @@ type ClusterConfig struct {
 	MaxDistance         float64 `json:"max-distance"`
 	MinPointsPerSegment int     `json:"min-points-per-segment"`
 	MinPointsPerCluster int     `json:"min-points-per-cluster"`
+
+	// Algorithm is "grid" (default) or "dbscan"
+	// for dbscan, max-distance is eps and min-points-per-segment is the min points for a core point
+	Algorithm string
+
+	GeometryType string `json:"geometry_type"`
+	// LabelOrder is "size" (default, biggest is cluster-0) or "distance" (closest to the camera is cluster-0)
+	LabelOrder string `json:"label_order"`
+
+	// ConfidencePoints is how many points a cluster needs for a detection confidence of 1.
+	// Defaults to the size of the biggest cluster.
+	ConfidencePoints int `json:"confidence_points"`
+
+	// Tracking, if set, gives clusters persistent track-N labels across calls
+	Tracking *ClusterTrackingConfig `json:"tracking,omitempty"`
+}
+
+func (cc *ClusterConfig) cluster(pc pointcloud.PointCloud) ([]pointcloud.PointCloud, error) {
+	if cc.Algorithm == "dbscan" {
+		return ClusterDBSCAN(pc, cc.MaxDistance, cc.MinPointsPerSegment, cc.MinPointsPerCluster)
+	}
+	return Cluster(pc, cc.MaxDistance, cc.MinPointsPerSegment, cc.MinPointsPerCluster)
 }
 
 func (cc *ClusterConfig) Validate(p string) ([]string, []string, error) {
@@ -171,6 +350,29 @@ func (cc *ClusterConfig) Validate(p string) ([]string, []string, error) {
 		return nil, nil, fmt.Errorf("need to spefict min-points-per-cluster")
 	}
 
+	if cc.Algorithm != "" && cc.Algorithm != "grid" && cc.Algorithm != "dbscan" {
+		return nil, nil, fmt.Errorf("unknown algorithm [%s]", cc.Algorithm)
+	}
+
+	if !validGeometryType(cc.GeometryType) {
+		return nil, nil, fmt.Errorf("unknown geometry_type [%s]", cc.GeometryType)
+	}
+
+	if cc.LabelOrder != "" && cc.LabelOrder != "size" && cc.LabelOrder != "distance" {
+		return nil, nil, fmt.Errorf("unknown label_order [%s]", cc.LabelOrder)
+	}
+
+	if cc.ConfidencePoints < 0 {
+		return nil, nil, fmt.Errorf("confidence_points cannot be negative")
+	}
+
+	if cc.Tracking != nil {
+		err := cc.Tracking.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	return []string{cc.Camera}, nil, nil
 }
 
@@ -181,6 +383,8 @@ type ClusterService struct {
 	name resource.Name
 	cam  camera.Camera
 	conf *ClusterConfig
+
+	tracker *ClusterTracker
 }
 
 func newCluster(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (vision.Service, error) {
@@ -198,15 +402,111 @@ func newCluster(ctx context.Context, deps resource.Dependencies, config resource
 	if err != nil {
 		return nil, err
 	}
+
+	if newConf.Tracking != nil {
+		cs.tracker = NewClusterTracker(newConf.Tracking)
+	}
+
 	return cs, nil
 }
 
+func (cs *ClusterService) checkCameraName(cameraName string) error {
+	if cameraName != "" && cameraName != cs.conf.Camera {
+		return fmt.Errorf("bad cameraName %s", cameraName)
+	}
+	return nil
+}
+
 func (cs *ClusterService) DetectionsFromCamera(ctx context.Context, cameraName string, extra map[string]interface{}) ([]objectdetection.Detection, error) {
-	return nil, fmt.Errorf("n/a")
+	err := cs.checkCameraName(cameraName)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := cs.objects(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	return cs.detections(ctx, objects, nil)
 }
 
+// Detections isn't supported, clusters come from the camera's point cloud and there's no way
+// to get the one that goes with img.
 func (cs *ClusterService) Detections(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objectdetection.Detection, error) {
-	return nil, fmt.Errorf("n/a")
+	return nil, fmt.Errorf("only DetectionsFromCamera is supported, clusters come from the camera's point cloud")
+}
+
+func (cs *ClusterService) detections(ctx context.Context, objects []*viz.Object, bounds *image.Rectangle) ([]objectdetection.Detection, error) {
+	props, err := cs.cam.Properties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ObjectsToDetections(objects, props, bounds, cs.conf.ConfidencePoints)
+}
+
+// ObjectsToDetections projects each object's points into the image with the camera intrinsics,
+// so the points need to be in the camera frame.
+// The score is the point count over confidencePoints (or the biggest object if 0), capped at 1.
+// bounds defaults to the intrinsics width and height.
+func ObjectsToDetections(
+	objects []*viz.Object,
+	props camera.Properties,
+	bounds *image.Rectangle,
+	confidencePoints int,
+) ([]objectdetection.Detection, error) {
+	if props.IntrinsicParams == nil {
+		return nil, fmt.Errorf("intrinsics cannot be null")
+	}
+
+	if bounds == nil {
+		bounds = &image.Rectangle{Max: image.Point{props.IntrinsicParams.Width, props.IntrinsicParams.Height}}
+	}
+
+	if confidencePoints <= 0 {
+		for _, o := range objects {
+			confidencePoints = max(confidencePoints, o.Size())
+		}
+	}
+
+	detections := []objectdetection.Detection{}
+
+	for idx, o := range objects {
+		box := image.Rectangle{}
+		first := true
+
+		o.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			if p.Z <= 0 {
+				return true
+			}
+			x, y := props.IntrinsicParams.PointToPixel(p.X, p.Y, p.Z)
+			pt := image.Point{int(x), int(y)}
+			if first {
+				box = image.Rectangle{pt, pt.Add(image.Point{1, 1})}
+				first = false
+			} else {
+				box = box.Union(image.Rectangle{pt, pt.Add(image.Point{1, 1})})
+			}
+			return true
+		})
+
+		box = box.Intersect(*bounds)
+		if box.Empty() {
+			continue
+		}
+
+		label := fmt.Sprintf("cluster-%d", idx)
+		if o.Geometry != nil {
+			label = o.Geometry.Label()
+		}
+
+		score := math.Min(1, float64(o.Size())/float64(confidencePoints))
+
+		detections = append(detections, objectdetection.NewDetection(*bounds, box, score, label))
+	}
+
+	return detections, nil
 }
 
 func (cs *ClusterService) ClassificationsFromCamera(
@@ -228,28 +528,67 @@ func (cs *ClusterService) Classifications(
 }
 
 func (cs *ClusterService) GetObjectPointClouds(ctx context.Context, cameraName string, extra map[string]interface{}) ([]*viz.Object, error) {
-	if cameraName != "" && cameraName != cs.conf.Camera {
-		return nil, fmt.Errorf("bad cameraName %s", cameraName)
+	err := cs.checkCameraName(cameraName)
+	if err != nil {
+		return nil, err
 	}
+	return cs.objects(ctx, extra)
+}
 
+func (cs *ClusterService) objects(ctx context.Context, extra map[string]interface{}) ([]*viz.Object, error) {
 	pc, err := cs.cam.NextPointCloud(ctx, extra)
 	if err != nil {
 		return nil, err
 	}
 
-	clusters, err := Cluster(pc, cs.conf.MaxDistance, cs.conf.MinPointsPerSegment, cs.conf.MinPointsPerCluster)
+	clusters, err := cs.conf.cluster(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := ClustersToObjects(clusters, cs.conf.GeometryType, cs.conf.LabelOrder)
 	if err != nil {
 		return nil, err
 	}
 
+	if cs.tracker != nil {
+		cs.tracker.Update(objects, time.Now())
+	}
+
+	return objects, nil
+}
+
+// ClustersToObjects orders clusters by labelOrder ("size" or "distance" from the origin) and labels them
+// cluster-0, cluster-1, ... with a geometry of geometryType.
+func ClustersToObjects(clusters []pointcloud.PointCloud, geometryType, labelOrder string) ([]*viz.Object, error) {
+	clusters = append([]pointcloud.PointCloud{}, clusters...)
+
+	switch labelOrder {
+	case "", "size":
+		sort.SliceStable(clusters, func(i, j int) bool {
+			return clusters[i].Size() > clusters[j].Size()
+		})
+	case "distance":
+		distances := map[pointcloud.PointCloud]float64{}
+		for _, c := range clusters {
+			distances[c] = pointcloud.CloudCentroid(c).Norm()
+		}
+		sort.SliceStable(clusters, func(i, j int) bool {
+			return distances[clusters[i]] < distances[clusters[j]]
+		})
+	default:
+		return nil, fmt.Errorf("unknown label order [%s]", labelOrder)
+	}
+
 	os := []*viz.Object{}
 
-	for _, c := range clusters {
-		o, err := viz.NewObject(c)
+	for idx, c := range clusters {
+		label := fmt.Sprintf("cluster-%d", idx)
+		g, err := PCGeometry(c, geometryType, label)
 		if err != nil {
 			return nil, err
 		}
-		os = append(os, o)
+		os = append(os, &viz.Object{PointCloud: c, Geometry: g})
 	}
 
 	return os, nil
@@ -257,6 +596,7 @@ func (cs *ClusterService) GetObjectPointClouds(ctx context.Context, cameraName s
 
 func (cs *ClusterService) GetProperties(ctx context.Context, extra map[string]interface{}) (*vision.Properties, error) {
 	return &vision.Properties{
+		DetectionSupported:  true,
 		ObjectPCDsSupported: true,
 	}, nil
 }
@@ -265,10 +605,69 @@ func (cs *ClusterService) CaptureAllFromCamera(ctx context.Context,
 	cameraName string,
 	opts viscapture.CaptureOptions,
 	extra map[string]interface{}) (viscapture.VisCapture, error) {
-	return viscapture.VisCapture{}, fmt.Errorf("n/a")
+	err := cs.checkCameraName(cameraName)
+	if err != nil {
+		return viscapture.VisCapture{}, err
+	}
+
+	res := viscapture.VisCapture{}
+
+	if opts.ReturnImage {
+		res.Image, err = camera.DecodeImageFromCamera(ctx, "", extra, cs.cam)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if !opts.ReturnDetections && !opts.ReturnObject {
+		return res, nil
+	}
+
+	// one point cloud for both so they match
+	objects, err := cs.objects(ctx, extra)
+	if err != nil {
+		return res, err
+	}
+
+	if opts.ReturnObject {
+		res.Objects = objects
+	}
+
+	if opts.ReturnDetections {
+		var bounds *image.Rectangle
+		if res.Image != nil {
+			b := res.Image.Bounds()
+			bounds = &b
+		}
+		res.Detections, err = cs.detections(ctx, objects, bounds)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
 }
 
 func (cs *ClusterService) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["tracks"] == true || cmd["reset_tracks"] == true {
+		if cs.tracker == nil {
+			return nil, fmt.Errorf("tracking is not configured")
+		}
+	}
+
+	if cmd["tracks"] == true {
+		tracks := []interface{}{}
+		for _, t := range cs.tracker.Tracks() {
+			tracks = append(tracks, t.toMap())
+		}
+		return map[string]interface{}{"tracks": tracks}, nil
+	}
+
+	if cmd["reset_tracks"] == true {
+		cs.tracker.Reset()
+		return map[string]interface{}{}, nil
+	}
+
 	return nil, nil
 }
 
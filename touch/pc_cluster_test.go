@@ -1,8 +1,15 @@
 package touch
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
 	"testing"
 
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/test"
 )
@@ -16,6 +23,78 @@ func TestCluster1(t *testing.T) {
 	test.That(t, len(clusters), test.ShouldEqual, 1)
 }
 
+func makeClusterScene(t testing.TB) pointcloud.PointCloud {
+	pc := pointcloud.NewBasicEmpty()
+	addCube := func(center r3.Vector, side, step float64) {
+		for x := 0.0; x < side; x += step {
+			for y := 0.0; y < side; y += step {
+				for z := 0.0; z < side; z += step {
+					test.That(t, pc.Set(center.Add(r3.Vector{x, y, z}), pointcloud.NewBasicData()), test.ShouldBeNil)
+				}
+			}
+		}
+	}
+	addCube(r3.Vector{0, 0, 0}, 50, 5)
+	addCube(r3.Vector{200, 0, 0}, 30, 5)
+	addCube(r3.Vector{0, 200, 0}, 20, 5)
+	// a few stray points
+	test.That(t, pc.Set(r3.Vector{500, 500, 500}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{-500, 500, 500}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	return pc
+}
+
+func clusterSizes(clusters []pointcloud.PointCloud) []int {
+	sizes := []int{}
+	for _, c := range clusters {
+		sizes = append(sizes, c.Size())
+	}
+	sort.Ints(sizes)
+	return sizes
+}
+
+func TestClusterScene(t *testing.T) {
+	in := makeClusterScene(t)
+
+	clusters, err := Cluster(in, 10, 1, 10)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, clusterSizes(clusters), test.ShouldResemble, []int{64, 216, 1000})
+	// biggest first
+	test.That(t, clusters[0].Size(), test.ShouldEqual, 1000)
+
+	clusters, err = ClusterDBSCAN(in, 6, 4, 10)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, clusterSizes(clusters), test.ShouldResemble, []int{64, 216, 1000})
+
+	// with a big eps everything is one
+	clusters, err = ClusterDBSCAN(in, 1000, 4, 10)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, clusterSizes(clusters), test.ShouldResemble, []int{1282})
+
+	_, err = Cluster(in, 0, 1, 10)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestClusterMatchesPairwise(t *testing.T) {
+	if testing.Short() {
+		t.Skip("clusterPairwise is slow")
+	}
+
+	for _, fn := range []string{"data/glass1.pcd", "data/cup1.pcd"} {
+		in, err := pointcloud.NewFromFile(fn, "")
+		test.That(t, err, test.ShouldBeNil)
+
+		for _, maxDistance := range []float64{10, 30} {
+			a, err := Cluster(in, maxDistance, 20, 100)
+			test.That(t, err, test.ShouldBeNil)
+
+			b, err := clusterPairwise(in, maxDistance, 20, 100)
+			test.That(t, err, test.ShouldBeNil)
+
+			test.That(t, clusterSizes(a), test.ShouldResemble, clusterSizes(b))
+		}
+	}
+}
+
 func BenchmarkCluster1(t *testing.B) {
 	in, err := pointcloud.NewFromFile("data/glass1.pcd", "")
 	test.That(t, err, test.ShouldBeNil)
@@ -27,3 +106,182 @@ func BenchmarkCluster1(t *testing.B) {
 		test.That(t, len(clusters), test.ShouldEqual, 1)
 	}
 }
+
+func BenchmarkClusterPairwise1(t *testing.B) {
+	in, err := pointcloud.NewFromFile("data/glass1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	t.ResetTimer()
+	for range t.N {
+		clusters, err := clusterPairwise(in, 30, 20, 100)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(clusters), test.ShouldEqual, 1)
+	}
+}
+
+func BenchmarkClusterDBSCAN1(t *testing.B) {
+	in, err := pointcloud.NewFromFile("data/glass1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	t.ResetTimer()
+	for range t.N {
+		_, err := ClusterDBSCAN(in, 10, 20, 100)
+		test.That(t, err, test.ShouldBeNil)
+	}
+}
+
+func BenchmarkClusterBig(t *testing.B) {
+	in, err := pointcloud.NewFromFile("data/test.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	t.ResetTimer()
+	for range t.N {
+		_, err := Cluster(in, 30, 20, 100)
+		test.That(t, err, test.ShouldBeNil)
+	}
+}
+
+// clusterPairwise is the original implementation of Cluster, kept to check results and compare speed.
+func clusterPairwise(pc pointcloud.PointCloud, maxDistance float64, minPointsPerSegment, minPointsPerCluster int) ([]pointcloud.PointCloud, error) {
+	within := func(a, b pointcloud.PointCloud, within float64) bool {
+		amd := a.MetaData()
+		bmd := b.MetaData()
+
+		d := math.Abs(amd.Center().Distance(bmd.Center()))
+		if d > (amd.MaxSideLength() + bmd.MaxSideLength()) {
+			return false
+		}
+
+		good := false
+		a.Iterate(0, 0, func(ap r3.Vector, _ pointcloud.Data) bool {
+			b.Iterate(0, 0, func(bp r3.Vector, _ pointcloud.Data) bool {
+				d := math.Abs(ap.Distance(bp))
+				if d < within {
+					good = true
+					return false
+				}
+				return true
+			})
+			return true
+		})
+
+		return good
+	}
+
+	merge := func(a, b *pointcloud.BasicOctree) (*pointcloud.BasicOctree, error) {
+		x := pointcloud.NewBasicPointCloud(a.Size() + b.Size())
+		for _, src := range []pointcloud.PointCloud{a, b} {
+			err := pointcloud.ApplyOffset(src, nil, x)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return pointcloud.ToBasicOctree(x, 0)
+	}
+
+	buckets := map[string]pointcloud.PointCloud{}
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		b := fmt.Sprintf("%d-%d-%d",
+			int(math.Ceil(p.X/maxDistance)),
+			int(math.Ceil(p.Y/maxDistance)),
+			int(math.Ceil(p.Z/maxDistance)))
+
+		pc, ok := buckets[b]
+		if !ok {
+			pc = pointcloud.NewBasicPointCloud(0)
+			buckets[b] = pc
+		}
+		err := pc.Set(p, d)
+		if err != nil {
+			panic(err)
+		}
+		return true
+	})
+
+	segments := []*pointcloud.BasicOctree{}
+
+	for _, b := range buckets {
+		if minPointsPerSegment > 0 && b.Size() < minPointsPerSegment {
+			continue
+		}
+
+		o, err := pointcloud.ToBasicOctree(b, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, o)
+	}
+
+	for {
+		start := len(segments)
+		for x := 0; x < len(segments); x++ {
+			for y := x + 1; y < len(segments); y++ {
+				if within(segments[x], segments[y], maxDistance) {
+					n, err := merge(segments[x], segments[y])
+					if err != nil {
+						return nil, err
+					}
+					segments[x] = n
+					segments = append(segments[0:y], segments[y+1:]...)
+				}
+			}
+		}
+		if len(segments) == start {
+			break
+		}
+	}
+
+	clusters := []pointcloud.PointCloud{}
+	for _, o := range segments {
+		if o.Size() > minPointsPerCluster {
+			clusters = append(clusters, o)
+		}
+	}
+
+	return clusters, nil
+}
+
+func TestObjectsToDetections(t *testing.T) {
+	in := makeClusterScene(t)
+
+	// move the scene in front of the camera
+	shifted := pointcloud.NewBasicEmpty()
+	in.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		test.That(t, shifted.Set(p.Add(r3.Vector{-100, -100, 1000}), d), test.ShouldBeNil)
+		return true
+	})
+
+	clusters, err := Cluster(shifted, 10, 1, 10)
+	test.That(t, err, test.ShouldBeNil)
+
+	objects, err := ClustersToObjects(clusters, "", "size")
+	test.That(t, err, test.ShouldBeNil)
+
+	detections, err := ObjectsToDetections(objects, RealSenseProperties, nil, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(detections), test.ShouldEqual, 3)
+
+	test.That(t, detections[0].Label(), test.ShouldEqual, "cluster-0")
+	test.That(t, detections[0].Score(), test.ShouldEqual, 1)
+	test.That(t, detections[1].Score(), test.ShouldAlmostEqual, .216)
+
+	// the biggest cube is 45mm wide at 1000mm, so about 40 pixels
+	box := detections[0].BoundingBox()
+	test.That(t, box.Dx(), test.ShouldBeBetween, 35, 50)
+	test.That(t, box.Dy(), test.ShouldBeBetween, 35, 50)
+
+	x, y := RealSenseProperties.IntrinsicParams.PointToPixel(-100, -100, 1000)
+	test.That(t, box.Min.X, test.ShouldAlmostEqual, int(x), 1)
+	test.That(t, box.Min.Y, test.ShouldAlmostEqual, int(y), 1)
+
+	_, err = ObjectsToDetections(objects, camera.Properties{}, nil, 0)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestClusterServiceDetections(t *testing.T) {
+	// there's no point cloud to go with an image, even a missing one
+	_, err := (&ClusterService{}).Detections(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,338 @@
+package touch
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	viz "go.viam.com/rdk/vision"
+)
+
+type ClusterTrackingConfig struct {
+	// GatingDistance is how far (mm) a cluster can be from where a track is expected to be and still match
+	GatingDistance float64 `json:"gating_distance_mm"`
+	// MaxMissed is how many frames a track can go unseen before it is dropped
+	MaxMissed int `json:"max_missed"`
+	// HistorySize is how many positions to keep per track
+	HistorySize int `json:"history_size"`
+}
+
+func (c *ClusterTrackingConfig) Validate() error {
+	if c.GatingDistance < 0 {
+		return fmt.Errorf("gating_distance_mm cannot be negative")
+	}
+	if c.MaxMissed < 0 || c.HistorySize < 0 {
+		return fmt.Errorf("max_missed and history_size cannot be negative")
+	}
+	return nil
+}
+
+func (c *ClusterTrackingConfig) gatingDistance() float64 {
+	if c.GatingDistance <= 0 {
+		return 50
+	}
+	return c.GatingDistance
+}
+
+func (c *ClusterTrackingConfig) maxMissed() int {
+	if c.MaxMissed <= 0 {
+		return 5
+	}
+	return c.MaxMissed
+}
+
+func (c *ClusterTrackingConfig) historySize() int {
+	if c.HistorySize <= 0 {
+		return 20
+	}
+	return c.HistorySize
+}
+
+type TrackSample struct {
+	Time     time.Time
+	Centroid r3.Vector
+}
+
+type ClusterTrack struct {
+	ID       int
+	Centroid r3.Vector
+	Extent   r3.Vector
+	// Velocity is in mm/s
+	Velocity  r3.Vector
+	Missed    int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	History   []TrackSample
+}
+
+func (t *ClusterTrack) Label() string {
+	return fmt.Sprintf("track-%d", t.ID)
+}
+
+// predict is where we expect the track to be at when.
+func (t *ClusterTrack) predict(when time.Time) r3.Vector {
+	return t.Centroid.Add(t.Velocity.Mul(when.Sub(t.LastSeen).Seconds()))
+}
+
+func (t *ClusterTrack) toMap() map[string]interface{} {
+	history := []interface{}{}
+	for _, s := range t.History {
+		history = append(history, map[string]interface{}{
+			"time":     s.Time.Format(time.RFC3339Nano),
+			"centroid": s.Centroid,
+		})
+	}
+	return map[string]interface{}{
+		"id":         t.ID,
+		"label":      t.Label(),
+		"centroid":   t.Centroid,
+		"extent":     t.Extent,
+		"velocity":   t.Velocity,
+		"missed":     t.Missed,
+		"first_seen": t.FirstSeen.Format(time.RFC3339Nano),
+		"last_seen":  t.LastSeen.Format(time.RFC3339Nano),
+		"history":    history,
+	}
+}
+
+// ClusterTracker gives clusters ids that stay the same from frame to frame.
+type ClusterTracker struct {
+	cfg *ClusterTrackingConfig
+
+	mu     sync.Mutex
+	tracks []*ClusterTrack
+	nextID int
+}
+
+func NewClusterTracker(cfg *ClusterTrackingConfig) *ClusterTracker {
+	if cfg == nil {
+		cfg = &ClusterTrackingConfig{}
+	}
+	return &ClusterTracker{cfg: cfg}
+}
+
+func cloudExtent(pc pointcloud.PointCloud) r3.Vector {
+	md := pc.MetaData()
+	return r3.Vector{md.MaxX - md.MinX, md.MaxY - md.MinY, md.MaxZ - md.MinZ}
+}
+
+// Update matches objects to the existing tracks, starts new tracks for objects that don't match,
+// and relabels each object's geometry with its track label.
+func (ct *ClusterTracker) Update(objects []*viz.Object, now time.Time) []*ClusterTrack {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	centroids := make([]r3.Vector, len(objects))
+	extents := make([]r3.Vector, len(objects))
+	for i, o := range objects {
+		centroids[i] = pointcloud.CloudCentroid(o)
+		extents[i] = cloudExtent(o)
+	}
+
+	gate := ct.cfg.gatingDistance()
+
+	cost := make([][]float64, len(ct.tracks))
+	for ti, t := range ct.tracks {
+		cost[ti] = make([]float64, len(objects))
+		expected := t.predict(now)
+		for oi := range objects {
+			d := expected.Distance(centroids[oi])
+			if d > gate {
+				cost[ti][oi] = math.Inf(1)
+			} else {
+				cost[ti][oi] = d + t.Extent.Sub(extents[oi]).Norm()
+			}
+		}
+	}
+
+	assignment := hungarian(cost)
+
+	matched := make([]*ClusterTrack, len(objects))
+	keep := []*ClusterTrack{}
+
+	for ti, t := range ct.tracks {
+		oi := assignment[ti]
+		if oi < 0 || math.IsInf(cost[ti][oi], 1) {
+			t.Missed++
+			if t.Missed <= ct.cfg.maxMissed() {
+				keep = append(keep, t)
+			}
+			continue
+		}
+
+		dt := now.Sub(t.LastSeen).Seconds()
+		if dt > 0 {
+			t.Velocity = centroids[oi].Sub(t.Centroid).Mul(1 / dt)
+		}
+		t.Centroid = centroids[oi]
+		t.Extent = extents[oi]
+		t.Missed = 0
+		t.LastSeen = now
+		t.addHistory(now, ct.cfg.historySize())
+
+		matched[oi] = t
+		keep = append(keep, t)
+	}
+
+	for oi := range objects {
+		if matched[oi] != nil {
+			continue
+		}
+		t := &ClusterTrack{
+			ID:        ct.nextID,
+			Centroid:  centroids[oi],
+			Extent:    extents[oi],
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+		t.addHistory(now, ct.cfg.historySize())
+		ct.nextID++
+
+		matched[oi] = t
+		keep = append(keep, t)
+	}
+
+	ct.tracks = keep
+
+	for oi, o := range objects {
+		if o.Geometry != nil {
+			o.Geometry.SetLabel(matched[oi].Label())
+		}
+	}
+
+	return matched
+}
+
+func (t *ClusterTrack) addHistory(now time.Time, size int) {
+	t.History = append(t.History, TrackSample{now, t.Centroid})
+	if len(t.History) > size {
+		t.History = t.History[len(t.History)-size:]
+	}
+}
+
+// Tracks returns a copy of the current tracks.
+func (ct *ClusterTracker) Tracks() []ClusterTrack {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	res := []ClusterTrack{}
+	for _, t := range ct.tracks {
+		c := *t
+		c.History = append([]TrackSample{}, t.History...)
+		res = append(res, c)
+	}
+	return res
+}
+
+func (ct *ClusterTracker) Reset() {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.tracks = nil
+}
+
+// hungarian finds the assignment of rows to columns with the lowest total cost.
+// Returns the column for each row, or -1 if the row is not assigned.
+// Infinite costs are never preferred over leaving a row unassigned.
+func hungarian(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return []int{}
+	}
+	cols := len(cost[0])
+
+	// pad to square, with "unassigned" cells that cost more than any real match
+	n := max(rows, cols)
+
+	big := 1.0
+	for _, r := range cost {
+		for _, c := range r {
+			if !math.IsInf(c, 1) {
+				big += math.Abs(c)
+			}
+		}
+	}
+	unassigned := big
+	impossible := big * float64(n+1)
+
+	a := func(i, j int) float64 {
+		if i >= rows || j >= cols {
+			return unassigned
+		}
+		if math.IsInf(cost[i][j], 1) {
+			return impossible
+		}
+		return cost[i][j]
+	}
+
+	// 1 indexed potentials, from the classic O(n^3) algorithm
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	p := make([]int, n+1)
+	way := make([]int, n+1)
+
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0 := p[j0]
+			delta := math.Inf(1)
+			j1 := 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				cur := a(i0-1, j-1) - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+			if j0 == 0 {
+				break
+			}
+		}
+	}
+
+	res := make([]int, rows)
+	for i := range res {
+		res[i] = -1
+	}
+	for j := 1; j <= n; j++ {
+		i := p[j] - 1
+		if i < rows && j-1 < cols && !math.IsInf(cost[i][j-1], 1) {
+			res[i] = j - 1
+		}
+	}
+	return res
+}
@@ -0,0 +1,102 @@
+package touch
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/test"
+)
+
+func TestHungarian(t *testing.T) {
+	test.That(t, hungarian([][]float64{
+		{4, 1, 3},
+		{2, 0, 5},
+		{3, 2, 2},
+	}), test.ShouldResemble, []int{1, 0, 2})
+
+	inf := math.Inf(1)
+
+	// more rows than columns
+	test.That(t, hungarian([][]float64{
+		{1, 5},
+		{2, 1},
+		{0, 9},
+	}), test.ShouldResemble, []int{-1, 1, 0})
+
+	// impossible matches are left unassigned
+	test.That(t, hungarian([][]float64{
+		{inf, 1},
+		{inf, inf},
+	}), test.ShouldResemble, []int{1, -1})
+
+	test.That(t, hungarian([][]float64{{}, {}}), test.ShouldResemble, []int{-1, -1})
+	test.That(t, hungarian(nil), test.ShouldResemble, []int{})
+}
+
+func makeTrackerObjects(t *testing.T, centers ...r3.Vector) []*viz.Object {
+	clusters := []pointcloud.PointCloud{}
+	for _, c := range centers {
+		pc := pointcloud.NewBasicEmpty()
+		for x := 0; x < 10; x++ {
+			for y := 0; y < 10; y++ {
+				test.That(t, pc.Set(c.Add(r3.Vector{float64(x), float64(y), 0}), pointcloud.NewBasicData()), test.ShouldBeNil)
+			}
+		}
+		clusters = append(clusters, pc)
+	}
+	objects, err := ClustersToObjects(clusters, "", "size")
+	test.That(t, err, test.ShouldBeNil)
+	return objects
+}
+
+func labelsByX(objects []*viz.Object) map[int]string {
+	res := map[int]string{}
+	for _, o := range objects {
+		res[int(math.Round(pointcloud.CloudCentroid(o).X/100))] = o.Geometry.Label()
+	}
+	return res
+}
+
+func TestClusterTracker(t *testing.T) {
+	ct := NewClusterTracker(&ClusterTrackingConfig{GatingDistance: 30, MaxMissed: 1})
+
+	start := time.Now()
+
+	objects := makeTrackerObjects(t, r3.Vector{0, 0, 500}, r3.Vector{100, 0, 500}, r3.Vector{200, 0, 500})
+	ct.Update(objects, start)
+	first := labelsByX(objects)
+	test.That(t, len(first), test.ShouldEqual, 3)
+
+	// same objects, moved a bit, in a different order
+	objects = makeTrackerObjects(t, r3.Vector{205, 0, 500}, r3.Vector{5, 0, 500}, r3.Vector{105, 0, 500})
+	ct.Update(objects, start.Add(time.Second))
+	test.That(t, labelsByX(objects), test.ShouldResemble, first)
+
+	tracks := ct.Tracks()
+	test.That(t, len(tracks), test.ShouldEqual, 3)
+	for _, tr := range tracks {
+		test.That(t, tr.Velocity.X, test.ShouldAlmostEqual, 5, .01)
+		test.That(t, len(tr.History), test.ShouldEqual, 2)
+	}
+
+	// one goes away and a new one shows up far away
+	objects = makeTrackerObjects(t, r3.Vector{10, 0, 500}, r3.Vector{110, 0, 500}, r3.Vector{1000, 0, 500})
+	ct.Update(objects, start.Add(2*time.Second))
+	now := labelsByX(objects)
+	test.That(t, now[0], test.ShouldEqual, first[0])
+	test.That(t, now[1], test.ShouldEqual, first[1])
+	test.That(t, now[10], test.ShouldEqual, "track-3")
+	test.That(t, len(ct.Tracks()), test.ShouldEqual, 4)
+
+	// missed twice, so the track is dropped
+	ct.Update(objects, start.Add(3*time.Second))
+	test.That(t, len(ct.Tracks()), test.ShouldEqual, 3)
+
+	ct.Reset()
+	test.That(t, len(ct.Tracks()), test.ShouldEqual, 0)
+}
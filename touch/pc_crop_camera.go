@@ -12,6 +12,7 @@ import (
 	"go.viam.com/rdk/data"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/rimage"
 	"go.viam.com/rdk/robot"
@@ -34,19 +35,114 @@ func init() {
 type CropCameraConfig struct {
 	Src      string
 	SrcFrame string `json:"src_frame"`
-	Min      r3.Vector
-	Max      r3.Vector
+
+	// CropFrame is the frame Min, Max, and volumes without a frame are in, defaults to world.
+	// It is looked up every call, so it can move.
+	CropFrame string `json:"crop_frame"`
+	// OutputFrame is the frame the point cloud is returned in, defaults to CropFrame
+	OutputFrame string `json:"output_frame"`
+
+	Min r3.Vector
+	Max r3.Vector
+
+	// Volumes, if any are not exclude, are used instead of Min/Max
+	Volumes []CropVolume `json:"volumes,omitempty"`
 
 	GoodColors []ColorFilter `json:"good_colors"`
+
+	OutlierFilter *OutlierFilterConfig `json:"outlier_filter,omitempty"`
+
+	// FramesToFuse, if more than 1, grabs that many clouds and fuses them to reduce noise
+	FramesToFuse int `json:"frames_to_fuse"`
+	// FuseVoxelSize is the size (mm) of the voxels clouds are fused on
+	FuseVoxelSize float64 `json:"fuse_voxel_size_mm"`
+	// FuseMinFrames is how many of the clouds a voxel has to be in to be kept
+	FuseMinFrames int `json:"fuse_min_frames"`
+
+	// Render controls how Image and Images draw the point cloud
+	Render *RenderOptions `json:"render,omitempty"`
 }
 
 func (ccc *CropCameraConfig) Validate(path string) ([]string, []string, error) {
 	if ccc.Src == "" {
 		return nil, nil, fmt.Errorf("need a src camera")
 	}
+	if ccc.Render != nil {
+		err := ccc.Render.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if ccc.OutlierFilter != nil {
+		err := ccc.OutlierFilter.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, cf := range ccc.GoodColors {
+		err := cf.Validate()
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad good_colors %d: %w", i, err)
+		}
+	}
+	for i, v := range ccc.Volumes {
+		_, err := v.Geometry.ParseConfig()
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad volume %d: %w", i, err)
+		}
+	}
+	if ccc.FramesToFuse < 0 || ccc.FuseVoxelSize < 0 || ccc.FuseMinFrames < 0 {
+		return nil, nil, fmt.Errorf("frames_to_fuse, fuse_voxel_size_mm, and fuse_min_frames cannot be negative")
+	}
+	if ccc.FuseMinFrames > ccc.FramesToFuse && ccc.FramesToFuse > 1 {
+		return nil, nil, fmt.Errorf("fuse_min_frames (%d) cannot be more than frames_to_fuse (%d)", ccc.FuseMinFrames, ccc.FramesToFuse)
+	}
 	return []string{ccc.Src}, nil, nil
 }
 
+type CropVolume struct {
+	Geometry spatialmath.GeometryConfig
+	// Frame the geometry is in, defaults to crop_frame
+	Frame string
+	// Exclude removes points in the geometry instead of keeping them
+	Exclude bool
+}
+
+func (ccc *CropCameraConfig) srcFrame() string {
+	if ccc.SrcFrame != "" {
+		return ccc.SrcFrame
+	}
+	return ccc.Src
+}
+
+func (ccc *CropCameraConfig) cropFrame() string {
+	if ccc.CropFrame != "" {
+		return ccc.CropFrame
+	}
+	return referenceframe.World
+}
+
+func (ccc *CropCameraConfig) outputFrame() string {
+	if ccc.OutputFrame != "" {
+		return ccc.OutputFrame
+	}
+	return ccc.cropFrame()
+}
+
+func (ccc *CropCameraConfig) fuseVoxelSize() float64 {
+	if ccc.FuseVoxelSize <= 0 {
+		return 2
+	}
+	return ccc.FuseVoxelSize
+}
+
+func (ccc *CropCameraConfig) fuseMinFrames() int {
+	if ccc.FuseMinFrames <= 0 {
+		return (ccc.FramesToFuse + 1) / 2
+	}
+	return ccc.FuseMinFrames
+}
+
 func newCropCamera(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (camera.Camera, error) {
 	newConf, err := resource.NativeConfig[*CropCameraConfig](config)
 	if err != nil {
@@ -98,7 +194,10 @@ func (cc *cropCamera) Image(ctx context.Context, mimeType string, extra map[stri
 	if err != nil {
 		return nil, camera.ImageMetadata{}, err
 	}
-	img := PCToImage(pc)
+	img, err := PCToImageWithOptions(pc, cc.cfg.Render)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
 
 	data, err := rimage.EncodeImage(ctx, img, mimeType)
 	if err != nil {
@@ -114,7 +213,10 @@ func (cc *cropCamera) Images(ctx context.Context, filterSourceNames []string, ex
 		return nil, resource.ResponseMetadata{}, err
 	}
 	start := time.Now()
-	img := PCToImage(pc)
+	img, err := PCToImageWithOptions(pc, cc.cfg.Render)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
 	elapsed := time.Since(start)
 	if elapsed > (time.Millisecond * 100) {
 		cc.logger.Infof("PCToImage took %v", elapsed)
@@ -178,6 +280,10 @@ func (cc *cropCamera) waitForPointCloudAfter(ctx context.Context, when time.Time
 }
 
 func (cc *cropCamera) doNextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	if cc.cfg.FramesToFuse > 1 {
+		return cc.doNextFusedPointCloud(ctx, extra)
+	}
+
 	start := time.Now()
 
 	pc, err := cc.src.NextPointCloud(ctx, extra)
@@ -187,27 +293,162 @@ func (cc *cropCamera) doNextPointCloud(ctx context.Context, extra map[string]int
 
 	timeA := time.Since(start)
 
-	srcFrame := cc.cfg.Src
-	if cc.cfg.SrcFrame != "" {
-		srcFrame = cc.cfg.SrcFrame
+	pc, err = cc.client.TransformPointCloud(ctx, pc, cc.cfg.srcFrame(), cc.cfg.cropFrame())
+	if err != nil {
+		return nil, err
+	}
+
+	timeB := time.Since(start)
+
+	include, exclude, err := cc.volumesInCropFrame(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	pc, err = cc.client.TransformPointCloud(ctx, pc, srcFrame, "world")
+	pc, err = cc.crop(pc, include, exclude, cc.cfg.GoodColors)
+	if err != nil {
+		return nil, err
+	}
+	timeC := time.Since(start)
+
+	if cc.cfg.OutlierFilter != nil {
+		pc, err = cc.cfg.OutlierFilter.Apply(pc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	timeD := time.Since(start)
+
+	pc, err = cc.toOutputFrame(ctx, pc)
+	if err != nil {
+		return nil, err
+	}
+	timeE := time.Since(start)
+
+	if timeE > (time.Millisecond * 250) {
+		cc.logger.Infof("cropCamera::NextPointCloud timeA: %v timeB: %v timeC: %v timeD: %v timeE: %v", timeA, timeB, timeC, timeD, timeE)
+	}
+
+	return pc, nil
+
+}
+
+func (cc *cropCamera) doNextFusedPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	start := time.Now()
+
+	camPose, err := cc.client.TransformPose(ctx, referenceframe.NewPoseInFrame(cc.cfg.srcFrame(), spatialmath.NewZeroPose()), cc.cfg.cropFrame(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	include, exclude, err := cc.volumesInCropFrame(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pcs := []pointcloud.PointCloud{}
+	for range cc.cfg.FramesToFuse {
+		pc, err := cc.src.NextPointCloud(ctx, extra)
+		if err != nil {
+			return nil, err
+		}
+
+		pc, err = cc.client.TransformPointCloud(ctx, pc, cc.cfg.srcFrame(), cc.cfg.cropFrame())
+		if err != nil {
+			return nil, err
+		}
+
+		// crop before fusing so there is less to fuse, colors are checked after
+		pc, err = cc.crop(pc, include, exclude, nil)
+		if err != nil {
+			return nil, err
+		}
+		pcs = append(pcs, pc)
+	}
+
+	timeA := time.Since(start)
+
+	pc, err := PCFuse(pcs, camPose.Pose().Point(), cc.cfg.fuseVoxelSize(), cc.cfg.fuseMinFrames())
 	if err != nil {
 		return nil, err
 	}
 
 	timeB := time.Since(start)
 
-	pc = PCCropWithColor(pc, cc.cfg.Min, cc.cfg.Max, cc.cfg.GoodColors)
+	if len(cc.cfg.GoodColors) > 0 {
+		pc, err = cc.crop(pc, include, exclude, cc.cfg.GoodColors)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cc.cfg.OutlierFilter != nil {
+		pc, err = cc.cfg.OutlierFilter.Apply(pc)
+		if err != nil {
+			return nil, err
+		}
+	}
 	timeC := time.Since(start)
 
-	if timeC > (time.Millisecond * 250) {
-		cc.logger.Infof("cropCamera::NextPointCloud timeA: %v timeB: %v timeC: %v", timeA, timeB, timeC)
+	pc, err = cc.toOutputFrame(ctx, pc)
+	if err != nil {
+		return nil, err
+	}
+	timeD := time.Since(start)
+
+	if timeD > (time.Millisecond * 250) {
+		cc.logger.Infof("cropCamera::NextPointCloud fused %d frames timeA: %v timeB: %v timeC: %v timeD: %v", cc.cfg.FramesToFuse, timeA, timeB, timeC, timeD)
 	}
 
 	return pc, nil
+}
 
+func (cc *cropCamera) toOutputFrame(ctx context.Context, pc pointcloud.PointCloud) (pointcloud.PointCloud, error) {
+	if cc.cfg.outputFrame() == cc.cfg.cropFrame() {
+		return pc, nil
+	}
+	return cc.client.TransformPointCloud(ctx, pc, cc.cfg.cropFrame(), cc.cfg.outputFrame())
+}
+
+// volumesInCropFrame returns the configured volumes in the crop frame, split into include and exclude.
+func (cc *cropCamera) volumesInCropFrame(ctx context.Context) ([]spatialmath.Geometry, []spatialmath.Geometry, error) {
+	include := []spatialmath.Geometry{}
+	exclude := []spatialmath.Geometry{}
+
+	for _, v := range cc.cfg.Volumes {
+		g, err := v.Geometry.ParseConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if v.Frame != "" && v.Frame != cc.cfg.cropFrame() {
+			framePose, err := cc.client.TransformPose(ctx, referenceframe.NewPoseInFrame(v.Frame, spatialmath.NewZeroPose()), cc.cfg.cropFrame(), nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			g = g.Transform(framePose.Pose())
+		}
+
+		if v.Exclude {
+			exclude = append(exclude, g)
+		} else {
+			include = append(include, g)
+		}
+	}
+
+	return include, exclude, nil
+}
+
+// crop uses Min/Max if there are no include volumes.
+func (cc *cropCamera) crop(pc pointcloud.PointCloud, include, exclude []spatialmath.Geometry, colorFilters []ColorFilter) (pointcloud.PointCloud, error) {
+	if len(include) == 0 {
+		pc = PCCropWithColor(pc, cc.cfg.Min, cc.cfg.Max, colorFilters)
+		if len(exclude) == 0 {
+			return pc, nil
+		}
+		colorFilters = nil
+	}
+	return PCCropWithVolumes(pc, include, exclude, colorFilters)
 }
 
 func (cc *cropCamera) Properties(ctx context.Context) (camera.Properties, error) {
@@ -38,12 +38,21 @@ type DetectCropCameraConfig struct {
 
 	Labels []string
 	Min    float64
+
+	// Render controls how Image and Images draw the point cloud
+	Render *RenderOptions `json:"render,omitempty"`
 }
 
 func (dccc *DetectCropCameraConfig) Validate(path string) ([]string, []string, error) {
 	if dccc.Src == "" {
 		return nil, nil, fmt.Errorf("need a src camera")
 	}
+	if dccc.Render != nil {
+		err := dccc.Render.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 	if dccc.Service == "" {
 		return nil, nil, fmt.Errorf("need a service")
 	}
@@ -121,7 +130,10 @@ func (dcc *detectCropCamera) Image(ctx context.Context, mimeType string, extra m
 	if err != nil {
 		return nil, camera.ImageMetadata{}, err
 	}
-	img := PCToImage(pc)
+	img, err := PCToImageWithOptions(pc, dcc.cfg.Render)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
 
 	data, err := rimage.EncodeImage(ctx, img, mimeType)
 	if err != nil {
@@ -136,17 +148,33 @@ func (dcc *detectCropCamera) Images(ctx context.Context, filterSourceNames []str
 	if err != nil {
 		return nil, resource.ResponseMetadata{}, err
 	}
-	start := time.Now()
-	img := PCToImage(pc)
-	elapsed := time.Since(start)
-	if elapsed > (time.Millisecond * 100) {
-		dcc.logger.Infof("PCToImage took %v", elapsed)
+	res := []camera.NamedImage{}
+
+	if wantSourceName(filterSourceNames, "cropped") {
+		start := time.Now()
+		img, err := PCToImageWithOptions(pc, dcc.cfg.Render)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		elapsed := time.Since(start)
+		if elapsed > (time.Millisecond * 100) {
+			dcc.logger.Infof("PCToImage took %v", elapsed)
+		}
+		ni, err := camera.NamedImageFromImage(img, "cropped", "image/png", data.Annotations{})
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		res = append(res, ni)
 	}
-	ni, err := camera.NamedImageFromImage(img, "cropped", "image/png", data.Annotations{})
+
+	// aligned and depth line up with the src camera's images
+	aligned, err := intrinsicsNamedImages(pc, dcc.props, filterSourceNames)
 	if err != nil {
 		return nil, resource.ResponseMetadata{}, err
 	}
-	return []camera.NamedImage{ni}, resource.ResponseMetadata{time.Now()}, nil
+	res = append(res, aligned...)
+
+	return res, resource.ResponseMetadata{time.Now()}, nil
 }
 
 func (dcc *detectCropCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
@@ -0,0 +1,81 @@
+package touch
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+type fuseAccumulator struct {
+	voxelAccumulator
+	depths    []float64
+	frames    int
+	lastFrame int
+}
+
+// PCFuse combines several clouds of the same scene, all in the same frame, into one with a point per voxel
+// of side voxelSize (mm). Voxels seen in fewer than minFrames of the clouds are dropped.
+// Each point is at the median distance from viewpoint (where the camera is) along the average direction of the
+// points in the voxel, so noise in depth is removed, and has the average color.
+func PCFuse(pcs []pointcloud.PointCloud, viewpoint r3.Vector, voxelSize float64, minFrames int) (pointcloud.PointCloud, error) {
+	if voxelSize <= 0 {
+		return nil, fmt.Errorf("voxelSize has to be positive, got %v", voxelSize)
+	}
+	if len(pcs) == 0 {
+		return nil, fmt.Errorf("no point clouds to fuse")
+	}
+
+	voxels := map[voxelKey]*fuseAccumulator{}
+	order := []voxelKey{}
+
+	for frame, pc := range pcs {
+		pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			k := newVoxelKey(p, voxelSize)
+			fa, ok := voxels[k]
+			if !ok {
+				fa = &fuseAccumulator{lastFrame: -1}
+				voxels[k] = fa
+				order = append(order, k)
+			}
+			fa.add(p, d)
+			fa.depths = append(fa.depths, p.Distance(viewpoint))
+			if fa.lastFrame != frame {
+				fa.frames++
+				fa.lastFrame = frame
+			}
+			return true
+		})
+	}
+
+	out := pointcloud.NewBasicEmpty()
+
+	for _, k := range order {
+		fa := voxels[k]
+		if fa.frames < minFrames {
+			continue
+		}
+
+		sort.Float64s(fa.depths)
+		n := len(fa.depths)
+		depth := fa.depths[n/2]
+		if n%2 == 0 {
+			depth = (fa.depths[n/2-1] + fa.depths[n/2]) / 2
+		}
+
+		p := fa.centroid()
+		dir := p.Sub(viewpoint)
+		if dir.Norm2() > 0 {
+			p = viewpoint.Add(dir.Normalize().Mul(depth))
+		}
+
+		err := out.Set(p, fa.averageData())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package touch
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+)
+
+func TestPCFuse(t *testing.T) {
+	noise := []float64{0, .6, -.4, 5, .2}
+
+	pcs := []pointcloud.PointCloud{}
+	for frame, n := range noise {
+		pc := pointcloud.NewBasicEmpty()
+		for x := 0; x < 10; x++ {
+			for y := 0; y < 10; y++ {
+				p := r3.Vector{float64(x*10) + 5, float64(y*10) + 5, 1003 + n}
+				c := color.NRGBA{uint8(100 + frame*10), 0, 0, 255}
+				test.That(t, pc.Set(p, pointcloud.NewColoredData(c)), test.ShouldBeNil)
+			}
+		}
+		pcs = append(pcs, pc)
+	}
+
+	// a speck only seen once
+	test.That(t, pcs[0].Set(r3.Vector{500, 500, 500}, pointcloud.NewBasicData()), test.ShouldBeNil)
+
+	fused, err := PCFuse(pcs, r3.Vector{}, 10, 3)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fused.Size(), test.ShouldEqual, 100)
+
+	fused.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		// median of the distances ignores the bad frame
+		test.That(t, math.Abs(p.Norm()-r3.Vector{p.X, p.Y, 1003.2}.Norm()), test.ShouldBeLessThan, .1)
+		r, _, _ := d.RGB255()
+		test.That(t, r, test.ShouldEqual, 120)
+		return true
+	})
+
+	_, err = PCFuse(pcs, r3.Vector{}, 0, 3)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = PCFuse(nil, r3.Vector{}, 10, 3)
+	test.That(t, err, test.ShouldNotBeNil)
+}
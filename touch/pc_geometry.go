@@ -0,0 +1,281 @@
+package touch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+)
+
+const (
+	GeometryTypeAABB   = "aabb"
+	GeometryTypeOBB    = "obb"
+	GeometryTypeSphere = "sphere"
+	GeometryTypeHull   = "hull"
+)
+
+func validGeometryType(t string) bool {
+	switch t {
+	case "", GeometryTypeAABB, GeometryTypeOBB, GeometryTypeSphere, GeometryTypeHull:
+		return true
+	}
+	return false
+}
+
+// PCGeometry makes a geometry of geometryType (aabb if empty) that encloses pc.
+func PCGeometry(pc pointcloud.PointCloud, geometryType, label string) (spatialmath.Geometry, error) {
+	switch geometryType {
+	case "", GeometryTypeAABB:
+		return pointcloud.BoundingBoxFromPointCloudWithLabel(pc, label)
+	case GeometryTypeOBB:
+		return PCOrientedBoundingBox(pc, label)
+	case GeometryTypeSphere:
+		return PCBoundingSphere(pc, label)
+	case GeometryTypeHull:
+		hull, err := PCConvexHull(pc, label)
+		if errors.Is(err, errFlatHull) {
+			// a flat or tiny cluster has no volume to wrap, the box is the best there is
+			return PCOrientedBoundingBox(pc, label)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return hull, nil
+	}
+	return nil, fmt.Errorf("unknown geometry type [%s]", geometryType)
+}
+
+// PCPrincipalAxes returns the centroid of pc and its principal axes, biggest variance first.
+// The axes are unit length and form a right handed frame.
+func PCPrincipalAxes(pc pointcloud.PointCloud) (r3.Vector, [3]r3.Vector, error) {
+	if pc.Size() == 0 {
+		return r3.Vector{}, [3]r3.Vector{}, fmt.Errorf("empty point cloud")
+	}
+	centroid, axes, _, err := principalAxes(pointcloud.CloudToPoints(pc))
+	return centroid, axes, err
+}
+
+// principalAxes returns the centroid of points, their principal axes biggest spread first,
+// and how spread out the points are along each. The axes are unit length and form a right handed frame.
+func principalAxes(points []r3.Vector) (r3.Vector, [3]r3.Vector, [3]float64, error) {
+	axes := [3]r3.Vector{}
+	spread := [3]float64{}
+
+	centroid := r3.Vector{}
+	for _, p := range points {
+		centroid = centroid.Add(p)
+	}
+	centroid = centroid.Mul(1 / float64(len(points)))
+
+	cov := make([]float64, 9)
+	for _, p := range points {
+		v := p.Sub(centroid)
+		xyz := [3]float64{v.X, v.Y, v.Z}
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				cov[3*r+c] += xyz[r] * xyz[c]
+			}
+		}
+	}
+
+	var eig mat.EigenSym
+	if !eig.Factorize(mat.NewSymDense(3, cov), true) {
+		return r3.Vector{}, axes, spread, fmt.Errorf("cannot find principal axes")
+	}
+
+	values := eig.Values(nil)
+	var vecs mat.Dense
+	eig.VectorsTo(&vecs)
+
+	// eigenvalues are ascending
+	for i := 0; i < 3; i++ {
+		axes[i] = r3.Vector{vecs.At(0, 2-i), vecs.At(1, 2-i), vecs.At(2, 2-i)}.Normalize()
+		spread[i] = values[2-i]
+	}
+	axes[2] = axes[0].Cross(axes[1]).Normalize()
+
+	return centroid, axes, spread, nil
+}
+
+// PCOrientedBoundingBox returns a box aligned to the principal axes of pc that contains all of it.
+func PCOrientedBoundingBox(pc pointcloud.PointCloud, label string) (spatialmath.Geometry, error) {
+	centroid, axes, err := PCPrincipalAxes(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	min := r3.Vector{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := r3.Vector{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		v := p.Sub(centroid)
+		local := r3.Vector{v.Dot(axes[0]), v.Dot(axes[1]), v.Dot(axes[2])}
+		min = r3.Vector{math.Min(min.X, local.X), math.Min(min.Y, local.Y), math.Min(min.Z, local.Z)}
+		max = r3.Vector{math.Max(max.X, local.X), math.Max(max.Y, local.Y), math.Max(max.Z, local.Z)}
+		return true
+	})
+
+	localCenter := min.Add(max).Mul(.5)
+	center := centroid.
+		Add(axes[0].Mul(localCenter.X)).
+		Add(axes[1].Mul(localCenter.Y)).
+		Add(axes[2].Mul(localCenter.Z))
+
+	dims := max.Sub(min)
+
+	return spatialmath.NewBox(mat3FromCols(axes[0], axes[1], axes[2]).pose(center), dims, label)
+}
+
+// PCBoundingSphere returns a sphere around the centroid of pc that contains all of it.
+func PCBoundingSphere(pc pointcloud.PointCloud, label string) (spatialmath.Geometry, error) {
+	if pc.Size() == 0 {
+		return nil, fmt.Errorf("empty point cloud")
+	}
+
+	centroid := pointcloud.CloudCentroid(pc)
+
+	radius := 0.0
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		radius = math.Max(radius, p.Distance(centroid))
+		return true
+	})
+
+	return spatialmath.NewSphere(spatialmath.NewPoseFromPoint(centroid), radius, label)
+}
+
+type hullFace struct {
+	a, b, c int
+	normal  r3.Vector
+	offset  float64
+}
+
+func newHullFace(points []r3.Vector, a, b, c int, inside r3.Vector) hullFace {
+	n := points[b].Sub(points[a]).Cross(points[c].Sub(points[a])).Normalize()
+	if n.Dot(inside.Sub(points[a])) > 0 {
+		b, c = c, b
+		n = n.Mul(-1)
+	}
+	return hullFace{a, b, c, n, -n.Dot(points[a])}
+}
+
+func (f hullFace) distance(p r3.Vector) float64 {
+	return f.normal.Dot(p) + f.offset
+}
+
+// errFlatHull is why PCConvexHull fails when the points don't have any volume.
+var errFlatHull = errors.New("points don't have a hull")
+
+// PCConvexHull returns the convex hull of pc as a mesh in the frame of pc.
+func PCConvexHull(pc pointcloud.PointCloud, label string) (*spatialmath.Mesh, error) {
+	points := pointcloud.CloudToPoints(pc)
+	if len(points) == 0 {
+		return nil, fmt.Errorf("empty point cloud")
+	}
+	if len(points) < 4 {
+		return nil, fmt.Errorf("%w, need at least 4 points, have %d", errFlatHull, len(points))
+	}
+
+	const eps = 1e-6
+
+	// start with the biggest tetrahedron we can easily find
+	i0 := 0
+	for i, p := range points {
+		if p.X < points[i0].X {
+			i0 = i
+		}
+	}
+
+	farthest := func(score func(p r3.Vector) float64) (int, float64) {
+		best, bestScore := -1, 0.0
+		for i, p := range points {
+			s := score(p)
+			if s > bestScore {
+				best, bestScore = i, s
+			}
+		}
+		return best, bestScore
+	}
+
+	i1, d := farthest(func(p r3.Vector) float64 { return p.Distance(points[i0]) })
+	if d < eps {
+		return nil, fmt.Errorf("%w, they are all the same", errFlatHull)
+	}
+
+	line := points[i1].Sub(points[i0]).Normalize()
+	i2, d := farthest(func(p r3.Vector) float64 { return p.Sub(points[i0]).Cross(line).Norm() })
+	if d < eps {
+		return nil, fmt.Errorf("%w, they are colinear", errFlatHull)
+	}
+
+	planeNormal := points[i1].Sub(points[i0]).Cross(points[i2].Sub(points[i0])).Normalize()
+	i3, d := farthest(func(p r3.Vector) float64 { return math.Abs(p.Sub(points[i0]).Dot(planeNormal)) })
+	if d < eps {
+		return nil, fmt.Errorf("%w, they are coplanar", errFlatHull)
+	}
+
+	inside := points[i0].Add(points[i1]).Add(points[i2]).Add(points[i3]).Mul(.25)
+
+	faces := []hullFace{
+		newHullFace(points, i0, i1, i2, inside),
+		newHullFace(points, i0, i1, i3, inside),
+		newHullFace(points, i0, i2, i3, inside),
+		newHullFace(points, i1, i2, i3, inside),
+	}
+
+	type edge struct{ a, b int }
+
+	for i, p := range points {
+		if i == i0 || i == i1 || i == i2 || i == i3 {
+			continue
+		}
+
+		visible := make([]bool, len(faces))
+		anyVisible := false
+		for fi, f := range faces {
+			if f.distance(p) > eps {
+				visible[fi] = true
+				anyVisible = true
+			}
+		}
+		if !anyVisible {
+			continue
+		}
+
+		visibleEdges := map[edge]bool{}
+		for fi, f := range faces {
+			if visible[fi] {
+				visibleEdges[edge{f.a, f.b}] = true
+				visibleEdges[edge{f.b, f.c}] = true
+				visibleEdges[edge{f.c, f.a}] = true
+			}
+		}
+
+		newFaces := make([]hullFace, 0, len(faces))
+		for fi, f := range faces {
+			if !visible[fi] {
+				newFaces = append(newFaces, f)
+			}
+		}
+
+		// the horizon is the edges of visible faces whose other side is not visible
+		for e := range visibleEdges {
+			if !visibleEdges[edge{e.b, e.a}] {
+				newFaces = append(newFaces, newHullFace(points, e.a, e.b, i, inside))
+			}
+		}
+
+		faces = newFaces
+	}
+
+	triangles := make([]*spatialmath.Triangle, 0, len(faces))
+	for _, f := range faces {
+		triangles = append(triangles, spatialmath.NewTriangle(points[f.a], points[f.b], points[f.c]))
+	}
+
+	return spatialmath.NewMesh(spatialmath.NewZeroPose(), triangles, label), nil
+}
@@ -0,0 +1,153 @@
+package touch
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+// makeRotatedSlab makes a 100x20x4 slab of points rotated 45 degrees around Z.
+func makeRotatedSlab(t testing.TB) pointcloud.PointCloud {
+	pose := spatialmath.NewPose(r3.Vector{500, 0, 0}, &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: 45})
+	pc := pointcloud.NewBasicEmpty()
+	for x := 0.0; x <= 100; x += 2 {
+		for y := 0.0; y <= 20; y += 2 {
+			for z := 0.0; z <= 4; z += 2 {
+				p := spatialmath.Compose(pose, spatialmath.NewPoseFromPoint(r3.Vector{x - 50, y - 10, z - 2})).Point()
+				test.That(t, pc.Set(p, pointcloud.NewBasicData()), test.ShouldBeNil)
+			}
+		}
+	}
+	return pc
+}
+
+func TestPCOrientedBoundingBox(t *testing.T) {
+	in := makeRotatedSlab(t)
+
+	aabb, err := PCGeometry(in, GeometryTypeAABB, "a")
+	test.That(t, err, test.ShouldBeNil)
+
+	obb, err := PCGeometry(in, GeometryTypeOBB, "o")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, obb.Label(), test.ShouldEqual, "o")
+
+	dims := boxDims(obb)
+	test.That(t, dims.X, test.ShouldAlmostEqual, 100, .01)
+	test.That(t, dims.Y, test.ShouldAlmostEqual, 20, .01)
+	test.That(t, dims.Z, test.ShouldAlmostEqual, 4, .01)
+
+	test.That(t, obb.Pose().Point().X, test.ShouldAlmostEqual, 500, .01)
+	test.That(t, obb.Pose().Point().Y, test.ShouldAlmostEqual, 0, .01)
+
+	// the oriented box is much smaller than the axis aligned one
+	aabbDims := boxDims(aabb)
+	test.That(t, dims.X*dims.Y*dims.Z, test.ShouldBeLessThan, aabbDims.X*aabbDims.Y*aabbDims.Z/2)
+
+	// and everything is in it
+	inverse := spatialmath.PoseInverse(obb.Pose())
+	in.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		local := spatialmath.Compose(inverse, spatialmath.NewPoseFromPoint(p)).Point()
+		test.That(t, math.Abs(local.X), test.ShouldBeLessThanOrEqualTo, dims.X/2+.001)
+		test.That(t, math.Abs(local.Y), test.ShouldBeLessThanOrEqualTo, dims.Y/2+.001)
+		test.That(t, math.Abs(local.Z), test.ShouldBeLessThanOrEqualTo, dims.Z/2+.001)
+		return true
+	})
+}
+
+func boxDims(g spatialmath.Geometry) r3.Vector {
+	d := g.ToProtobuf().GetBox().GetDimsMm()
+	return r3.Vector{d.X, d.Y, d.Z}
+}
+
+func TestPCBoundingSphere(t *testing.T) {
+	in := makeRotatedSlab(t)
+
+	s, err := PCGeometry(in, GeometryTypeSphere, "s")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.Pose().Point().X, test.ShouldAlmostEqual, 500, .01)
+	test.That(t, s.Label(), test.ShouldEqual, "s")
+}
+
+func TestPCConvexHull(t *testing.T) {
+	in := makeRotatedSlab(t)
+
+	hull, err := PCConvexHull(in, "h")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, hull.Label(), test.ShouldEqual, "h")
+	test.That(t, len(hull.Triangles()), test.ShouldBeGreaterThanOrEqualTo, 12)
+
+	// every point is on or inside every face
+	for _, tri := range hull.Triangles() {
+		pts := tri.Points()
+		n := tri.Normal()
+		in.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			test.That(t, n.Dot(p.Sub(pts[0])), test.ShouldBeLessThan, 1e-3)
+			return true
+		})
+	}
+
+	_, err = PCConvexHull(pointcloud.NewBasicEmpty(), "")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPCGeometryFlatHull(t *testing.T) {
+	flat := pointcloud.NewBasicEmpty()
+	for x := 0.0; x <= 10; x += 2 {
+		for y := 0.0; y <= 10; y += 2 {
+			test.That(t, flat.Set(r3.Vector{x, y, 100}, pointcloud.NewBasicData()), test.ShouldBeNil)
+		}
+	}
+	line := pointcloud.NewBasicEmpty()
+	for x := 0.0; x <= 10; x += 2 {
+		test.That(t, line.Set(r3.Vector{x, 0, 100}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	}
+
+	_, err := PCConvexHull(flat, "h")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a hull can't wrap these, so they get a box
+	for _, pc := range []pointcloud.PointCloud{flat, line} {
+		g, err := PCGeometry(pc, GeometryTypeHull, "h")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, g.Label(), test.ShouldEqual, "h")
+		dims := boxDims(g)
+		test.That(t, dims.X, test.ShouldAlmostEqual, 10, .01)
+		test.That(t, dims.Z, test.ShouldAlmostEqual, 0, .01)
+		test.That(t, g.Pose().Point().Z, test.ShouldAlmostEqual, 100, .01)
+	}
+
+	objs, err := ClustersToObjects([]pointcloud.PointCloud{flat}, GeometryTypeHull, "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objs), test.ShouldEqual, 1)
+
+	_, err = PCGeometry(pointcloud.NewBasicEmpty(), GeometryTypeHull, "h")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestClustersToObjects(t *testing.T) {
+	near := pointcloud.NewBasicEmpty()
+	far := pointcloud.NewBasicEmpty()
+	for i := 0; i < 10; i++ {
+		test.That(t, near.Set(r3.Vector{float64(i), 0, 100}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	}
+	for i := 0; i < 20; i++ {
+		test.That(t, far.Set(r3.Vector{float64(i), 0, 1000}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	}
+
+	objs, err := ClustersToObjects([]pointcloud.PointCloud{near, far}, "", "size")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objs), test.ShouldEqual, 2)
+	test.That(t, objs[0].Geometry.Label(), test.ShouldEqual, "cluster-0")
+	test.That(t, objs[0].Size(), test.ShouldEqual, 20)
+	test.That(t, objs[1].Geometry.Label(), test.ShouldEqual, "cluster-1")
+
+	objs, err = ClustersToObjects([]pointcloud.PointCloud{far, near}, GeometryTypeSphere, "distance")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, objs[0].Size(), test.ShouldEqual, 10)
+	test.That(t, math.Abs(objs[0].Geometry.Pose().Point().Z-100), test.ShouldBeLessThan, .01)
+}
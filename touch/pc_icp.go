@@ -0,0 +1,264 @@
+package touch
+
+import (
+	"fmt"
+
+	"github.com/golang/geo/r3"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// ICPOptions tune RegisterICP. The zero value is reasonable for clouds in mm that are already close.
+type ICPOptions struct {
+	// MaxIterations defaults to 30
+	MaxIterations int `json:"max_iterations,omitempty"`
+	// MaxCorrespondenceDistance is how far (mm) apart matched points can be, defaults to 10
+	MaxCorrespondenceDistance float64 `json:"max_correspondence_distance_mm,omitempty"`
+	// VoxelSize, if set, downsamples both clouds (mm) before registering, which is a lot faster
+	VoxelSize float64 `json:"voxel_size_mm,omitempty"`
+	// NormalNeighbors is how many points are used for each normal, defaults to 10
+	NormalNeighbors int `json:"normal_neighbors,omitempty"`
+	// MinFitness is the fitness below which merging keeps the frame system pose, defaults to .3
+	MinFitness float64 `json:"min_fitness,omitempty"`
+}
+
+func (o *ICPOptions) Validate() error {
+	if o.MaxIterations < 0 || o.MaxCorrespondenceDistance < 0 || o.VoxelSize < 0 || o.NormalNeighbors < 0 {
+		return fmt.Errorf("icp options cannot be negative")
+	}
+	if o.NormalNeighbors > 0 && o.NormalNeighbors < 3 {
+		return fmt.Errorf("icp normal_neighbors has to be at least 3")
+	}
+	if o.MinFitness < 0 || o.MinFitness > 1 {
+		return fmt.Errorf("icp min_fitness has to be between 0 and 1")
+	}
+	return nil
+}
+
+func (o *ICPOptions) maxIterations() int {
+	if o.MaxIterations <= 0 {
+		return 30
+	}
+	return o.MaxIterations
+}
+
+func (o *ICPOptions) maxCorrespondenceDistance() float64 {
+	if o.MaxCorrespondenceDistance <= 0 {
+		return 10
+	}
+	return o.MaxCorrespondenceDistance
+}
+
+func (o *ICPOptions) normalNeighbors() int {
+	if o.NormalNeighbors <= 0 {
+		return 10
+	}
+	return o.NormalNeighbors
+}
+
+func (o *ICPOptions) minFitness() float64 {
+	if o.MinFitness <= 0 {
+		return .3
+	}
+	return o.MinFitness
+}
+
+// icpConverged is how small (mm or radians) an update has to be to stop iterating.
+const icpConverged = 1e-5
+
+// RegisterICP finds the pose that moves src onto dst with point to plane ICP, starting from initial (nil is no change).
+// Fitness is the fraction of src points that end up within MaxCorrespondenceDistance of dst, so 1 is a full overlap.
+func RegisterICP(src, dst pointcloud.PointCloud, initial spatialmath.Pose, opts *ICPOptions) (spatialmath.Pose, float64, error) {
+	if opts == nil {
+		opts = &ICPOptions{}
+	}
+	err := opts.Validate()
+	if err != nil {
+		return nil, 0, err
+	}
+	if initial == nil {
+		initial = spatialmath.NewZeroPose()
+	}
+	if src.Size() == 0 {
+		return nil, 0, fmt.Errorf("src point cloud is empty")
+	}
+	if dst.Size() < opts.normalNeighbors() {
+		return nil, 0, fmt.Errorf("dst point cloud needs at least %d points, has %d", opts.normalNeighbors(), dst.Size())
+	}
+
+	if opts.VoxelSize > 0 {
+		src, err = PCVoxelDownsample(src, opts.VoxelSize, VoxelModeCentroid)
+		if err != nil {
+			return nil, 0, err
+		}
+		dst, err = PCVoxelDownsample(dst, opts.VoxelSize, VoxelModeCentroid)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	srcPoints := make([]r3.Vector, 0, src.Size())
+	src.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		srcPoints = append(srcPoints, p)
+		return true
+	})
+
+	normals := newNormalEstimator(pointcloud.ToKDTree(dst), opts.normalNeighbors())
+	maxDistance := opts.maxCorrespondenceDistance()
+
+	pose := initial
+	moved := make([]r3.Vector, len(srcPoints))
+
+	for range opts.maxIterations() {
+		transformPoints(pose, srcPoints, moved)
+
+		// linearize around the centroid so the rotation part is well conditioned
+		center := r3.Vector{}
+		for _, p := range moved {
+			center = center.Add(p)
+		}
+		center = center.Mul(1 / float64(len(moved)))
+
+		ata := make([]float64, 36)
+		atb := make([]float64, 6)
+		matches := 0
+
+		for _, p := range moved {
+			q, _, dist, ok := normals.kd.NearestNeighbor(p)
+			if !ok || dist > maxDistance {
+				continue
+			}
+			n, ok := normals.at(q)
+			if !ok {
+				continue
+			}
+
+			residual := p.Sub(q).Dot(n)
+			c := p.Sub(center).Cross(n)
+			row := [6]float64{c.X, c.Y, c.Z, n.X, n.Y, n.Z}
+			for i := 0; i < 6; i++ {
+				for j := 0; j < 6; j++ {
+					ata[6*i+j] += row[i] * row[j]
+				}
+				atb[i] -= row[i] * residual
+			}
+			matches++
+		}
+
+		if matches < 6 {
+			return nil, 0, fmt.Errorf("only %d points within %v mm of dst, clouds are too far apart", matches, maxDistance)
+		}
+
+		// a little damping so directions nothing constrains (sliding along a flat wall) stay put
+		for i := 0; i < 6; i++ {
+			ata[7*i] += 1e-6 * float64(matches)
+		}
+
+		var chol mat.Cholesky
+		if !chol.Factorize(mat.NewSymDense(6, ata)) {
+			return nil, 0, fmt.Errorf("icp step failed, points don't constrain the pose")
+		}
+		var x mat.VecDense
+		err := chol.SolveVecTo(&x, mat.NewVecDense(6, atb))
+		if err != nil {
+			return nil, 0, fmt.Errorf("icp step failed: %w", err)
+		}
+
+		rotation := r3.Vector{x.AtVec(0), x.AtVec(1), x.AtVec(2)}
+		translation := r3.Vector{x.AtVec(3), x.AtVec(4), x.AtVec(5)}
+
+		// rotate about center, then translate
+		rot := spatialmath.NewPoseFromOrientation(rodrigues(rotation).orientation())
+		rotatedCenter := spatialmath.Compose(rot, spatialmath.NewPoseFromPoint(center)).Point()
+		step := spatialmath.NewPose(center.Sub(rotatedCenter).Add(translation), rot.Orientation())
+
+		pose = spatialmath.Compose(step, pose)
+
+		if rotation.Norm() < icpConverged && translation.Norm() < icpConverged {
+			break
+		}
+	}
+
+	transformPoints(pose, srcPoints, moved)
+	good := 0
+	for _, p := range moved {
+		_, _, dist, ok := normals.kd.NearestNeighbor(p)
+		if ok && dist <= maxDistance {
+			good++
+		}
+	}
+
+	return pose, float64(good) / float64(len(moved)), nil
+}
+
+// refineWithICP moves pc onto ref with RegisterICP if they line up at least MinFitness,
+// otherwise returns pc as is. Also returns the fitness.
+func refineWithICP(pc, ref pointcloud.PointCloud, opts *ICPOptions) (pointcloud.PointCloud, float64, error) {
+	pose, fitness, err := RegisterICP(pc, ref, nil, opts)
+	if err != nil {
+		return pc, 0, err
+	}
+	if fitness < opts.minFitness() {
+		return pc, fitness, nil
+	}
+
+	out := pointcloud.NewBasicPointCloud(pc.Size())
+	err = pointcloud.ApplyOffset(pc, pose, out)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, fitness, nil
+}
+
+func transformPoints(pose spatialmath.Pose, in, out []r3.Vector) {
+	rot := mat3FromOrientation(pose.Orientation())
+	t := pose.Point()
+	for i, p := range in {
+		out[i] = rot.mulVec(p).Add(t)
+	}
+}
+
+// normalEstimator finds the surface normal at points of a cloud from their neighbors,
+// only working out the ones that get asked for.
+type normalEstimator struct {
+	kd      *pointcloud.KDTree
+	k       int
+	normals map[r3.Vector]r3.Vector
+}
+
+func newNormalEstimator(kd *pointcloud.KDTree, k int) *normalEstimator {
+	return &normalEstimator{kd: kd, k: k, normals: map[r3.Vector]r3.Vector{}}
+}
+
+// at returns the normal at p, which has to be in the cloud, false if the neighbors don't make a surface.
+func (ne *normalEstimator) at(p r3.Vector) (r3.Vector, bool) {
+	n, ok := ne.normals[p]
+	if !ok {
+		n = ne.estimate(p)
+		ne.normals[p] = n
+	}
+	return n, n.Norm2() > 0
+}
+
+func (ne *normalEstimator) estimate(p r3.Vector) r3.Vector {
+	neighbors := ne.kd.KNearestNeighbors(p, ne.k, true)
+	if len(neighbors) < 3 {
+		return r3.Vector{}
+	}
+
+	points := make([]r3.Vector, 0, len(neighbors))
+	for _, n := range neighbors {
+		points = append(points, n.P)
+	}
+
+	_, axes, spread, err := principalAxes(points)
+	// a line or a single point doesn't have a normal
+	if err != nil || spread[1] <= 1e-9 {
+		return r3.Vector{}
+	}
+
+	// the normal is the direction the points are least spread along
+	return axes[2]
+}
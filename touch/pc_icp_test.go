@@ -0,0 +1,60 @@
+package touch
+
+import (
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+// icpTestCorner is three walls of a box, so every direction is pinned down.
+func icpTestCorner(t *testing.T) pointcloud.PointCloud {
+	pc := pointcloud.NewBasicEmpty()
+	for a := 0.0; a <= 60; a += 2 {
+		for b := 0.0; b <= 60; b += 2 {
+			test.That(t, pc.Set(r3.Vector{a, b, 500}, nil), test.ShouldBeNil)
+			test.That(t, pc.Set(r3.Vector{a, 0, 500 + b}, nil), test.ShouldBeNil)
+			test.That(t, pc.Set(r3.Vector{0, a, 500 + b}, nil), test.ShouldBeNil)
+		}
+	}
+	return pc
+}
+
+func TestRegisterICP(t *testing.T) {
+	dst := icpTestCorner(t)
+
+	offset := spatialmath.NewPose(r3.Vector{3, -2, 1.5}, &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: 2})
+	src := pointcloud.NewBasicEmpty()
+	test.That(t, pointcloud.ApplyOffset(dst, offset, src), test.ShouldBeNil)
+
+	pose, fitness, err := RegisterICP(src, dst, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fitness, test.ShouldBeGreaterThan, .99)
+
+	// pose should undo offset
+	back := spatialmath.Compose(pose, offset)
+	test.That(t, back.Point().Norm(), test.ShouldBeLessThan, .01)
+	test.That(t, spatialmath.OrientationAlmostEqual(back.Orientation(), spatialmath.NewZeroOrientation()), test.ShouldBeTrue)
+
+	// downsampling moves the points around a little, so it's only about right
+	pose2, _, err := RegisterICP(src, dst, nil, &ICPOptions{VoxelSize: 4})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, spatialmath.Compose(pose2, offset).Point().Norm(), test.ShouldBeLessThan, 1.5)
+
+	refined, fitness, err := refineWithICP(src, dst, &ICPOptions{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fitness, test.ShouldBeGreaterThan, .99)
+	test.That(t, refined.Size(), test.ShouldEqual, src.Size())
+
+	// too far apart to match anything
+	far := pointcloud.NewBasicEmpty()
+	test.That(t, pointcloud.ApplyOffset(dst, spatialmath.NewPoseFromPoint(r3.Vector{Z: 1000}), far), test.ShouldBeNil)
+	_, _, err = RegisterICP(far, dst, nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	test.That(t, (&ICPOptions{NormalNeighbors: 2}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&ICPOptions{MinFitness: 2}).Validate(), test.ShouldNotBeNil)
+}
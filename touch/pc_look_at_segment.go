@@ -37,12 +37,21 @@ func init() {
 type LookAtCameraConfig struct {
 	Src      string
 	UseColor bool `json:"use_color"`
+
+	// Render controls how Image and Images draw the point cloud
+	Render *RenderOptions `json:"render,omitempty"`
 }
 
 func (ccc *LookAtCameraConfig) Validate(path string) ([]string, []string, error) {
 	if ccc.Src == "" {
 		return nil, nil, fmt.Errorf("need a src camera")
 	}
+	if ccc.Render != nil {
+		err := ccc.Render.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 	return []string{ccc.Src}, nil, nil
 }
 
@@ -97,7 +106,10 @@ func (cc *lookAtCamera) Image(ctx context.Context, mimeType string, extra map[st
 	if err != nil {
 		return nil, camera.ImageMetadata{}, err
 	}
-	img := PCToImage(pc)
+	img, err := PCToImageWithOptions(pc, cc.cfg.Render)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
 
 	data, err := rimage.EncodeImage(ctx, img, mimeType)
 	if err != nil {
@@ -112,17 +124,35 @@ func (cc *lookAtCamera) Images(ctx context.Context, filterSourceNames []string,
 	if err != nil {
 		return nil, resource.ResponseMetadata{}, err
 	}
-	start := time.Now()
-	img := PCToImage(pc)
-	elapsed := time.Since(start)
-	if elapsed > (time.Millisecond * 100) {
-		cc.logger.Infof("PCToImage took %v", elapsed)
+	res := []camera.NamedImage{}
+
+	if wantSourceName(filterSourceNames, "cropped") {
+		start := time.Now()
+		img, err := PCToImageWithOptions(pc, cc.cfg.Render)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		elapsed := time.Since(start)
+		if elapsed > (time.Millisecond * 100) {
+			cc.logger.Infof("PCToImage took %v", elapsed)
+		}
+		ni, err := camera.NamedImageFromImage(img, "cropped", "image/png", data.Annotations{})
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		res = append(res, ni)
 	}
-	ni, err := camera.NamedImageFromImage(img, "cropped", "image/png", data.Annotations{})
-	if err != nil {
-		return nil, resource.ResponseMetadata{}, err
+
+	// aligned and depth line up with the src camera's images
+	if cc.srcProperties.IntrinsicParams != nil {
+		aligned, err := intrinsicsNamedImages(pc, cc.srcProperties, filterSourceNames)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		res = append(res, aligned...)
 	}
-	return []camera.NamedImage{ni}, resource.ResponseMetadata{time.Now()}, nil
+
+	return res, resource.ResponseMetadata{time.Now()}, nil
 }
 
 func (cc *lookAtCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
@@ -2,15 +2,24 @@ package touch
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"image/color"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/golang/geo/r3"
+
 	"go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/data"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/spatialmath"
 
 	"github.com/erh/vmodutils"
@@ -29,12 +38,90 @@ func init() {
 
 type MergeConfig struct {
 	Cameras []string
+
+	// SrcFrames is the frame each camera's cloud is in, by camera name, defaults to the camera
+	SrcFrames map[string]string `json:"src_frames,omitempty"`
+	// OutputFrame is the frame the merged cloud is in, defaults to world
+	OutputFrame string `json:"output_frame"`
+	// TagCameras sets each point's value to the index of the camera it came from
+	TagCameras bool `json:"tag_cameras"`
+
+	OutlierFilter *OutlierFilterConfig `json:"outlier_filter,omitempty"`
+
+	// Render controls how Image and Images draw the point cloud
+	Render *RenderOptions `json:"render,omitempty"`
+
+	// RefineWithICP lines each camera's cloud up with the ones before it, ICP tunes how
+	RefineWithICP bool        `json:"refine_with_icp"`
+	ICP           *ICPOptions `json:"icp,omitempty"`
+
+	// TimeoutSeconds is how long each camera gets, 0 is forever
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+	// RequireAll fails the merge if any camera fails, otherwise it merges whatever arrived
+	RequireAll bool `json:"require_all"`
+}
+
+func (c *MergeConfig) srcFrame(cam string) string {
+	if f := c.SrcFrames[cam]; f != "" {
+		return f
+	}
+	return cam
+}
+
+func (c *MergeConfig) outputFrame() string {
+	if c.OutputFrame != "" {
+		return c.OutputFrame
+	}
+	return referenceframe.World
+}
+
+func (c *MergeConfig) timeout() time.Duration {
+	return time.Duration(c.TimeoutSeconds * float64(time.Second))
+}
+
+// icpOptions is nil if not refining.
+func (c *MergeConfig) icpOptions() *ICPOptions {
+	if !c.RefineWithICP {
+		return nil
+	}
+	if c.ICP == nil {
+		return &ICPOptions{}
+	}
+	return c.ICP
 }
 
 func (c *MergeConfig) Validate(path string) ([]string, []string, error) {
 	if len(c.Cameras) == 0 {
 		return nil, nil, fmt.Errorf("need cameras")
 	}
+	if c.TimeoutSeconds < 0 {
+		return nil, nil, fmt.Errorf("timeout_seconds cannot be negative")
+	}
+	for cam := range c.SrcFrames {
+		if !slices.Contains(c.Cameras, cam) {
+			return nil, nil, fmt.Errorf("src_frames has %s which isn't in cameras", cam)
+		}
+	}
+	if c.Render != nil {
+		err := c.Render.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.OutlierFilter != nil {
+		err := c.OutlierFilter.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.ICP != nil {
+		err := c.ICP.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	return c.Cameras, nil, nil
 }
@@ -48,6 +135,7 @@ func newMerge(ctx context.Context, deps resource.Dependencies, config resource.C
 	cc := &MergeCamera{
 		name:    config.ResourceName(),
 		cfg:     newConf,
+		logger:  logger,
 		cameras: []camera.Camera{},
 	}
 
@@ -59,6 +147,11 @@ func newMerge(ctx context.Context, deps resource.Dependencies, config resource.C
 		cc.cameras = append(cc.cameras, c)
 	}
 
+	cc.fsSvc, err = framesystem.FromDependencies(deps)
+	if err != nil {
+		return nil, err
+	}
+
 	return cc, nil
 }
 
@@ -66,10 +159,62 @@ type MergeCamera struct {
 	resource.AlwaysRebuild
 	resource.TriviallyCloseable
 
-	name resource.Name
-	cfg  *MergeConfig
+	name   resource.Name
+	cfg    *MergeConfig
+	logger logging.Logger
 
 	cameras []camera.Camera
+	fsSvc   framesystem.Service
+
+	lock   sync.Mutex
+	latest *mergeResult
+}
+
+// mergeResult is what happened to each camera in one merge.
+type mergeResult struct {
+	at      time.Time
+	points  map[string]int
+	missing map[string]error
+	// ranges is only set with tag_cameras
+	ranges map[string][][2]int
+}
+
+// missingNames is sorted so it's the same every time.
+func (mr *mergeResult) missingNames() []string {
+	names := []string{}
+	for n := range mr.missing {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (mr *mergeResult) toMap() map[string]interface{} {
+	points := map[string]interface{}{}
+	for n, p := range mr.points {
+		points[n] = p
+	}
+	missing := map[string]interface{}{}
+	for n, err := range mr.missing {
+		missing[n] = err.Error()
+	}
+	m := map[string]interface{}{
+		"age_seconds": time.Since(mr.at).Seconds(),
+		"points":      points,
+		"missing":     missing,
+	}
+	if mr.ranges != nil {
+		ranges := map[string]interface{}{}
+		for n, rs := range mr.ranges {
+			list := []interface{}{}
+			for _, r := range rs {
+				list = append(list, []interface{}{r[0], r[1]})
+			}
+			ranges[n] = list
+		}
+		m["camera_ranges"] = ranges
+	}
+	return m
 }
 
 func (mapc *MergeCamera) Name() resource.Name {
@@ -81,7 +226,10 @@ func (mapc *MergeCamera) Image(ctx context.Context, mimeType string, extra map[s
 	if err != nil {
 		return nil, camera.ImageMetadata{}, err
 	}
-	img := PCToImage(pc)
+	img, err := PCToImageWithOptions(pc, mapc.cfg.Render)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
 
 	data, err := rimage.EncodeImage(ctx, img, mimeType)
 	if err != nil {
@@ -92,37 +240,190 @@ func (mapc *MergeCamera) Image(ctx context.Context, mimeType string, extra map[s
 }
 
 func (mapc *MergeCamera) Images(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
-	pc, err := mapc.NextPointCloud(ctx, extra)
+	pc, res, err := mapc.merge(ctx, extra)
 	if err != nil {
 		return nil, resource.ResponseMetadata{}, err
 	}
-	img := PCToImage(pc)
+	img, err := PCToImageWithOptions(pc, mapc.cfg.Render)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+
+	// cameras that didn't make it are classifications, since that's the only metadata images have
+	annotations := data.Annotations{}
+	for _, n := range res.missingNames() {
+		annotations.Classifications = append(annotations.Classifications, data.Classification{Label: "missing:" + n})
+	}
 
-	ni, err := camera.NamedImageFromImage(img, "cropped", "image/png", data.Annotations{})
+	ni, err := camera.NamedImageFromImage(img, "cropped", "image/png", annotations)
 	if err != nil {
 		return nil, resource.ResponseMetadata{}, err
 	}
-	return []camera.NamedImage{ni}, resource.ResponseMetadata{time.Now()}, nil
+	return []camera.NamedImage{ni}, resource.ResponseMetadata{res.at}, nil
 }
 
 func (mapc *MergeCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["status"] == true {
+		mapc.lock.Lock()
+		defer mapc.lock.Unlock()
+		if mapc.latest == nil {
+			return map[string]interface{}{}, nil
+		}
+		return mapc.latest.toMap(), nil
+	}
 	return nil, nil
 }
 
 func (mapc *MergeCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
-	inputs := []pointcloud.PointCloud{}
-	totalSize := 0
+	pc, _, err := mapc.merge(ctx, extra)
+	return pc, err
+}
 
-	for _, c := range mapc.cameras {
+// getInOutputFrame gets camera i's cloud, and if transform is set moves it into the output frame.
+// It gives up after the timeout even if the camera doesn't.
+func (mapc *MergeCamera) getInOutputFrame(ctx context.Context, i int, transform bool, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	if mapc.cfg.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, mapc.cfg.timeout())
+		defer cancel()
+	}
 
+	type result struct {
+		pc  pointcloud.PointCloud
+		err error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		c := mapc.cameras[i]
 		pc, err := c.NextPointCloud(ctx, extra)
 		if err != nil {
-			return nil, err
+			done <- result{nil, err}
+			return
 		}
 
-		totalSize += pc.Size()
+		out := pc
+		if transform {
+			pif, err := mapc.fsSvc.GetPose(ctx, mapc.cfg.srcFrame(c.Name().Name), mapc.cfg.outputFrame(), nil, nil)
+			if err != nil {
+				done <- result{nil, err}
+				return
+			}
+
+			out = pointcloud.NewBasicPointCloud(pc.Size())
+			err = pointcloud.ApplyOffset(pc, pif.Pose(), out)
+			if err != nil {
+				done <- result{nil, err}
+				return
+			}
+		}
+		if mapc.cfg.TagCameras {
+			out, err = tagPoints(out, i)
+		}
+		done <- result{out, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.pc, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// camerasToTransform is which cameras' clouds get moved into the output frame: the ones in src_frames and the ones
+// in the frame system. Any others are merged as they are, like before there were frames, since they're normally
+// cameras like pc-crop-camera that already return world points.
+func (mapc *MergeCamera) camerasToTransform(ctx context.Context) ([]bool, error) {
+	fsCfg, err := mapc.fsSvc.FrameSystemConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	frames := map[string]bool{}
+	for _, part := range fsCfg.Parts {
+		if part.FrameConfig != nil {
+			frames[part.FrameConfig.Name()] = true
+		}
+	}
+
+	transform := make([]bool, len(mapc.cfg.Cameras))
+	for i, name := range mapc.cfg.Cameras {
+		_, explicit := mapc.cfg.SrcFrames[name]
+		transform[i] = explicit || frames[name]
+		if !transform[i] {
+			mapc.logger.Debugf("%s isn't in the frame system, merging its cloud as it is", name)
+		}
+	}
+	return transform, nil
+}
+
+// merge gets every camera's cloud at the same time and merges them in the output frame.
+func (mapc *MergeCamera) merge(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, *mergeResult, error) {
+	start := time.Now()
+
+	transform, err := mapc.camerasToTransform(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	clouds := make([]pointcloud.PointCloud, len(mapc.cameras))
+	errs := make([]error, len(mapc.cameras))
+
+	var wg sync.WaitGroup
+	for i := range mapc.cameras {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clouds[i], errs[i] = mapc.getInOutputFrame(ctx, i, transform[i], extra)
+		}()
+	}
+	wg.Wait()
+
+	res := &mergeResult{at: start, points: map[string]int{}, missing: map[string]error{}}
+
+	inputs := []pointcloud.PointCloud{}
+	names := []string{}
+	totalSize := 0
+
+	for i, pc := range clouds {
+		name := mapc.cfg.Cameras[i]
+		if errs[i] != nil {
+			if mapc.cfg.RequireAll {
+				return nil, nil, fmt.Errorf("camera %s failed: %w", name, errs[i])
+			}
+			mapc.logger.Warnf("merging without %s: %v", name, errs[i])
+			res.missing[name] = errs[i]
+			continue
+		}
+
+		res.points[name] = pc.Size()
+		totalSize += pc.Size()
 		inputs = append(inputs, pc)
+		names = append(names, name)
+	}
+
+	if len(inputs) == 0 {
+		return nil, nil, fmt.Errorf("no cameras returned a point cloud: %w", errors.Join(errs...))
+	}
+
+	icp := mapc.cfg.icpOptions()
+	if icp != nil && len(inputs) > 1 {
+		accumulated := pointcloud.NewBasicPointCloud(totalSize)
+		for i, pc := range inputs {
+			if i > 0 {
+				refined, fitness, err := refineWithICP(pc, accumulated, icp)
+				if err != nil {
+					mapc.logger.Warnf("couldn't line up %s with icp: %v", names[i], err)
+				} else {
+					mapc.logger.Debugf("icp fitness for %s: %0.3f", names[i], fitness)
+					inputs[i] = refined
+				}
+			}
+			err := pointcloud.ApplyOffset(inputs[i], nil, accumulated)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
 	}
 
 	big := pointcloud.NewBasicPointCloud(totalSize)
@@ -130,11 +431,64 @@ func (mapc *MergeCamera) NextPointCloud(ctx context.Context, extra map[string]in
 	for _, pc := range inputs {
 		err := pointcloud.ApplyOffset(pc, nil, big)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	return big, nil
+	var out pointcloud.PointCloud = big
+	if mapc.cfg.OutlierFilter != nil {
+		out, err = mapc.cfg.OutlierFilter.Apply(big)
+	}
+	if err == nil && mapc.cfg.TagCameras {
+		res.ranges = cameraRanges(out, mapc.cfg.Cameras)
+	}
+
+	mapc.lock.Lock()
+	mapc.latest = res
+	mapc.lock.Unlock()
+
+	if err != nil {
+		return nil, res, err
+	}
+	return out, res, nil
+}
+
+// cameraRanges is where each camera's points are in pc, read back from the tags, as [start, end) index ranges.
+// The values don't make it through a PCD, so this is how clients on the other side of the wire tell cameras apart.
+func cameraRanges(pc pointcloud.PointCloud, cameras []string) map[string][][2]int {
+	ranges := map[string][][2]int{}
+	i := 0
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		name := cameras[d.Value()]
+		r := ranges[name]
+		if len(r) > 0 && r[len(r)-1][1] == i {
+			r[len(r)-1][1] = i + 1
+		} else {
+			ranges[name] = append(r, [2]int{i, i + 1})
+		}
+		i++
+		return true
+	})
+	return ranges
+}
+
+// tagPoints is pc with every point's value set to v. The data is copied since the camera might still be using it.
+func tagPoints(pc pointcloud.PointCloud, v int) (pointcloud.PointCloud, error) {
+	out := pointcloud.NewBasicPointCloud(pc.Size())
+	var err error
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		nd := pointcloud.NewValueData(v)
+		if d != nil {
+			if d.HasColor() {
+				r, g, b := d.RGB255()
+				nd.SetColor(color.NRGBA{r, g, b, 255})
+			}
+			nd.SetIntensity(d.Intensity())
+		}
+		err = out.Set(p, nd)
+		return err == nil
+	})
+	return out, err
 }
 
 func (mapc *MergeCamera) Properties(ctx context.Context) (camera.Properties, error) {
@@ -0,0 +1,217 @@
+package touch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils"
+)
+
+// testFrameSystem is an injected frame system service that has frames.
+type testFrameSystem struct {
+	*inject.FrameSystemService
+	frames []string
+}
+
+func (fs *testFrameSystem) FrameSystemConfig(ctx context.Context) (*framesystem.Config, error) {
+	cfg := &framesystem.Config{}
+	for _, name := range fs.frames {
+		cfg.Parts = append(cfg.Parts, &referenceframe.FrameSystemPart{
+			FrameConfig: referenceframe.NewLinkInFrame(referenceframe.World, spatialmath.NewZeroPose(), name, nil),
+		})
+	}
+	return cfg, nil
+}
+
+func newTestMerge(t *testing.T, cfg *MergeConfig) *MergeCamera {
+	onePoint := func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		pc := pointcloud.NewBasicEmpty()
+		err := pc.Set(r3.Vector{}, nil)
+		return pc, err
+	}
+
+	left := inject.NewCamera("left")
+	left.NextPointCloudFunc = onePoint
+	right := inject.NewCamera("right")
+	right.NextPointCloudFunc = onePoint
+	broken := inject.NewCamera("broken")
+	broken.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		return nil, errors.New("unplugged")
+	}
+	// ignores ctx, so only the timeout can stop it
+	slow := inject.NewCamera("slow")
+	slow.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		time.Sleep(time.Second)
+		return onePoint(ctx, extra)
+	}
+	// not in the frame system, like a pc-crop-camera that already returns world points
+	crop := inject.NewCamera("crop")
+	crop.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		pc := pointcloud.NewBasicEmpty()
+		err := pc.Set(r3.Vector{X: 5}, nil)
+		return pc, err
+	}
+
+	fsSvc := inject.NewFrameSystemService(framesystem.PublicServiceName.Name)
+	fsSvc.GetPoseFunc = func(
+		ctx context.Context,
+		componentName, destinationFrame string,
+		supplementalTransforms []*referenceframe.LinkInFrame,
+		extra map[string]interface{},
+	) (*referenceframe.PoseInFrame, error) {
+		x := 0.0
+		switch componentName {
+		case "right":
+			x = 100
+		case "lens":
+			x = 200
+		case "crop":
+			// it isn't in the frame system, so this shouldn't be used
+			x = 1000
+		}
+		// the table is 10mm along from world
+		if destinationFrame == "table" {
+			x -= 10
+		}
+		return referenceframe.NewPoseInFrame(destinationFrame, spatialmath.NewPoseFromPoint(r3.Vector{X: x})), nil
+	}
+
+	deps := resource.Dependencies{fsSvc.Name(): &testFrameSystem{fsSvc, []string{"left", "right", "broken", "slow", "lens", "table"}}}
+	for _, c := range []*inject.Camera{left, right, broken, slow, crop} {
+		deps[c.Name()] = c
+	}
+
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	res, err := newMerge(context.Background(), deps, resource.Config{
+		Name:                "merge",
+		API:                 camera.API,
+		Model:               resource.Model{Family: vmodutils.NamespaceFamily},
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	return res.(*MergeCamera)
+}
+
+func TestMergeCamera(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("clouds are put in the world frame", func(t *testing.T) {
+		cam := newTestMerge(t, &MergeConfig{Cameras: []string{"left", "right"}})
+
+		pc, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 2)
+		_, got := pc.At(100, 0, 0)
+		test.That(t, got, test.ShouldBeTrue)
+	})
+
+	t.Run("cameras not in the frame system are merged as they are", func(t *testing.T) {
+		cam := newTestMerge(t, &MergeConfig{Cameras: []string{"right", "crop"}})
+
+		pc, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 2)
+		_, got := pc.At(100, 0, 0)
+		test.That(t, got, test.ShouldBeTrue)
+		_, got = pc.At(5, 0, 0)
+		test.That(t, got, test.ShouldBeTrue)
+	})
+
+	t.Run("src_frames, output_frame, and tags", func(t *testing.T) {
+		cam := newTestMerge(t, &MergeConfig{
+			Cameras:     []string{"left", "right"},
+			SrcFrames:   map[string]string{"right": "lens"},
+			OutputFrame: "table",
+			TagCameras:  true,
+		})
+
+		pc, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 2)
+		d, got := pc.At(-10, 0, 0)
+		test.That(t, got, test.ShouldBeTrue)
+		test.That(t, d.Value(), test.ShouldEqual, 0)
+		d, got = pc.At(190, 0, 0)
+		test.That(t, got, test.ShouldBeTrue)
+		test.That(t, d.Value(), test.ShouldEqual, 1)
+
+		// the values don't survive a PCD, so the ranges in status are how clients over the wire tell
+		status, err := cam.DoCommand(ctx, map[string]interface{}{"status": true})
+		test.That(t, err, test.ShouldBeNil)
+		ranges := status["camera_ranges"].(map[string]interface{})
+		var buf bytes.Buffer
+		test.That(t, pointcloud.ToPCD(pc, &buf, pointcloud.PCDBinary), test.ShouldBeNil)
+		wire, err := pointcloud.ReadPCD(&buf, "")
+		test.That(t, err, test.ShouldBeNil)
+		xs := []float64{}
+		wire.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			xs = append(xs, p.X)
+			return true
+		})
+		for name, x := range map[string]float64{"left": -10, "right": 190} {
+			rs := ranges[name].([]interface{})
+			test.That(t, len(rs), test.ShouldEqual, 1)
+			r := rs[0].([]interface{})
+			test.That(t, r[1].(int)-r[0].(int), test.ShouldEqual, 1)
+			test.That(t, xs[r[0].(int)], test.ShouldAlmostEqual, x)
+		}
+
+		_, _, err = (&MergeConfig{Cameras: []string{"left"}, SrcFrames: map[string]string{"right": "lens"}}).Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("missing cameras are reported", func(t *testing.T) {
+		cam := newTestMerge(t, &MergeConfig{Cameras: []string{"left", "broken", "slow", "right"}, TimeoutSeconds: .05})
+
+		start := time.Now()
+		pc, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, time.Since(start), test.ShouldBeLessThan, 500*time.Millisecond)
+		test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+		status, err := cam.DoCommand(ctx, map[string]interface{}{"status": true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, status["points"], test.ShouldResemble, map[string]interface{}{"left": 1, "right": 1})
+		missing := status["missing"].(map[string]interface{})
+		test.That(t, missing["broken"], test.ShouldEqual, "unplugged")
+		test.That(t, missing, test.ShouldContainKey, "slow")
+
+		imgs, _, err := cam.Images(ctx, nil, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(imgs[0].Annotations().Classifications), test.ShouldEqual, 2)
+		test.That(t, imgs[0].Annotations().Classifications[0].Label, test.ShouldEqual, "missing:broken")
+	})
+
+	t.Run("require_all fails if one does", func(t *testing.T) {
+		cam := newTestMerge(t, &MergeConfig{Cameras: []string{"left", "broken"}, RequireAll: true})
+
+		_, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "broken")
+	})
+
+	t.Run("fails if none work", func(t *testing.T) {
+		cam := newTestMerge(t, &MergeConfig{Cameras: []string{"broken"}})
+
+		_, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+}
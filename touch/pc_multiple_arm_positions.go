@@ -3,14 +3,20 @@ package touch
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"go.viam.com/rdk/components/arm"
 	"go.viam.com/rdk/components/camera"
 	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/data"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
 	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/services/vision"
 	"go.viam.com/rdk/spatialmath"
 
 	"github.com/erh/vmodutils"
@@ -31,6 +37,38 @@ type MultipleArmPosesConfig struct {
 	Src          string
 	SleepSeconds float64 `json:"sleep_seconds"`
 	Positions    []string
+
+	// PoseImages adds the src camera's image from each position to Images as pose-0, pose-1, ...
+	PoseImages bool `json:"pose_images"`
+
+	// Render controls how Image and Images draw the merged point cloud
+	Render *RenderOptions `json:"render,omitempty"`
+
+	// Cached returns the last merged cloud instead of moving the arm every time,
+	// until DoCommand {"rescan": true} or it is older than MaxAgeSeconds (0 is forever)
+	Cached        bool    `json:"cached"`
+	MaxAgeSeconds float64 `json:"max_age_seconds"`
+
+	// RestoreArm, if set, is put back where it started after every scan, even if the scan fails or is canceled
+	RestoreArm string `json:"restore_arm,omitempty"`
+	// RestoreMotion, if set, moves RestoreArm back with motion planning around RestoreVisionServices' obstacles
+	RestoreMotion         string   `json:"restore_motion,omitempty"`
+	RestoreVisionServices []string `json:"restore_vision_services,omitempty"`
+
+	// RefineWithICP lines each position's cloud up with the ones before it, ICP tunes how
+	RefineWithICP bool        `json:"refine_with_icp"`
+	ICP           *ICPOptions `json:"icp,omitempty"`
+}
+
+// icpOptions is nil if not refining.
+func (c *MultipleArmPosesConfig) icpOptions() *ICPOptions {
+	if !c.RefineWithICP {
+		return nil
+	}
+	if c.ICP == nil {
+		return &ICPOptions{}
+	}
+	return c.ICP
 }
 
 func (c *MultipleArmPosesConfig) sleepTime() time.Duration {
@@ -40,6 +78,14 @@ func (c *MultipleArmPosesConfig) sleepTime() time.Duration {
 	return time.Duration(c.SleepSeconds * float64(time.Second))
 }
 
+// tooOld is if a cached scan should not be used anymore.
+func (c *MultipleArmPosesConfig) tooOld(scan *positionsScan) bool {
+	if c.MaxAgeSeconds <= 0 {
+		return false
+	}
+	return scan.age() > time.Duration(c.MaxAgeSeconds*float64(time.Second))
+}
+
 func (c *MultipleArmPosesConfig) Validate(path string) ([]string, []string, error) {
 	if c.Src == "" {
 		return nil, nil, fmt.Errorf("need a src camera")
@@ -49,7 +95,42 @@ func (c *MultipleArmPosesConfig) Validate(path string) ([]string, []string, erro
 		return nil, nil, fmt.Errorf("no positions")
 	}
 
-	return append(c.Positions, c.Src), nil, nil
+	if c.Render != nil {
+		err := c.Render.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.ICP != nil {
+		err := c.ICP.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.MaxAgeSeconds < 0 {
+		return nil, nil, fmt.Errorf("max_age_seconds cannot be negative")
+	}
+
+	deps := append([]string{}, c.Positions...)
+	deps = append(deps, c.Src)
+
+	if c.RestoreArm == "" {
+		if c.RestoreMotion != "" || len(c.RestoreVisionServices) > 0 {
+			return nil, nil, fmt.Errorf("restore_motion and restore_vision_services need a restore_arm")
+		}
+	} else {
+		deps = append(deps, c.RestoreArm)
+		if c.RestoreMotion == "builtin" {
+			deps = append(deps, motion.Named("builtin").String())
+		} else if c.RestoreMotion != "" {
+			deps = append(deps, c.RestoreMotion)
+		}
+		deps = append(deps, c.RestoreVisionServices...)
+	}
+
+	return deps, nil, nil
 }
 
 func newMultipleArmPoses(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (camera.Camera, error) {
@@ -61,6 +142,7 @@ func newMultipleArmPoses(ctx context.Context, deps resource.Dependencies, config
 	cc := &MultipleArmPosesCamera{
 		name:      config.ResourceName(),
 		cfg:       newConf,
+		logger:    logger,
 		positions: []toggleswitch.Switch{},
 	}
 
@@ -81,6 +163,27 @@ func newMultipleArmPoses(ctx context.Context, deps resource.Dependencies, config
 		return nil, err
 	}
 
+	if newConf.RestoreArm != "" {
+		cc.restore = &ArmRestore{Logger: logger}
+		cc.restore.Arm, err = arm.FromProvider(deps, newConf.RestoreArm)
+		if err != nil {
+			return nil, err
+		}
+		if newConf.RestoreMotion != "" {
+			cc.restore.Motion, err = motion.FromProvider(deps, newConf.RestoreMotion)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range newConf.RestoreVisionServices {
+			v, err := vision.FromProvider(deps, name)
+			if err != nil {
+				return nil, err
+			}
+			cc.restore.VisionServices = append(cc.restore.VisionServices, v)
+		}
+	}
+
 	return cc, nil
 }
 
@@ -88,33 +191,186 @@ type MultipleArmPosesCamera struct {
 	resource.AlwaysRebuild
 	resource.TriviallyCloseable
 
-	name resource.Name
-	cfg  *MultipleArmPosesConfig
+	name   resource.Name
+	cfg    *MultipleArmPosesConfig
+	logger logging.Logger
 
 	fsSvc framesystem.Service
 
 	src       camera.Camera
 	positions []toggleswitch.Switch
+	restore   *ArmRestore
+
+	// scanLock makes sure only one thing is moving the arm around at a time
+	scanLock sync.Mutex
+
+	lock     sync.Mutex
+	lastScan *positionsScan
+	scanning bool
 }
 
 func (mapc *MultipleArmPosesCamera) Name() resource.Name {
 	return mapc.name
 }
 
+// Image renders the last scan, it never moves the arm.
 func (mapc *MultipleArmPosesCamera) Image(ctx context.Context, mimeType string, extra map[string]interface{}) ([]byte, camera.ImageMetadata, error) {
-	return nil, camera.ImageMetadata{}, fmt.Errorf("image not supported")
+	scan, err := mapc.latestScan()
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	img, err := PCToImageWithOptions(scan.cloud, mapc.cfg.Render)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+
+	data, err := rimage.EncodeImage(ctx, img, mimeType)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+
+	return data, camera.ImageMetadata{MimeType: mimeType}, err
 }
 
-func (mapc *MultipleArmPosesCamera) Images(ctx context.Context, _ []string, _ map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
-	return nil, resource.ResponseMetadata{}, fmt.Errorf("image not supported")
+// Images is the last scan, like Image it never moves the arm.
+func (mapc *MultipleArmPosesCamera) Images(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	scan, err := mapc.latestScan()
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	pc := scan.cloud
+
+	res := []camera.NamedImage{}
+
+	if wantSourceName(filterSourceNames, "merged") {
+		start := time.Now()
+		img, err := PCToImageWithOptions(pc, mapc.cfg.Render)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		elapsed := time.Since(start)
+		if elapsed > (time.Millisecond * 100) {
+			mapc.logger.Infof("PCToImage took %v", elapsed)
+		}
+		ni, err := camera.NamedImageFromImage(img, "merged", "image/png", data.Annotations{})
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		res = append(res, ni)
+	}
+
+	for _, ni := range scan.images {
+		if wantSourceName(filterSourceNames, ni.SourceName) {
+			res = append(res, ni)
+		}
+	}
+
+	return res, resource.ResponseMetadata{time.Now()}, nil
 }
 
 func (mapc *MultipleArmPosesCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["rescan"] == true {
+		_, err := mapc.getScan(ctx, nil, true)
+		if err != nil {
+			return nil, err
+		}
+		return mapc.status(), nil
+	}
+
+	if cmd["status"] == true {
+		return mapc.status(), nil
+	}
+
 	return nil, nil
 }
 
 func (mapc *MultipleArmPosesCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
-	return GetMergedPointCloudFromPositions(ctx, mapc.positions, mapc.cfg.sleepTime(), mapc.src, extra, mapc.fsSvc, false)
+	scan, err := mapc.getScan(ctx, extra, false)
+	if err != nil {
+		return nil, err
+	}
+	return scan.cloud, nil
+}
+
+// latestScan is the last scan, for the images, which can't wait for the arm to move.
+func (mapc *MultipleArmPosesCamera) latestScan() (*positionsScan, error) {
+	mapc.lock.Lock()
+	defer mapc.lock.Unlock()
+	if mapc.lastScan == nil {
+		return nil, fmt.Errorf("no scan yet, get a point cloud or rescan first")
+	}
+	return mapc.lastScan, nil
+}
+
+// getScan returns the cached scan if we're cached and it's new enough, otherwise moves through all the positions.
+// Every scan gets the pose images if they're on, since Images only ever shows the last one.
+func (mapc *MultipleArmPosesCamera) getScan(ctx context.Context, extra map[string]interface{}, rescan bool) (*positionsScan, error) {
+	mapc.scanLock.Lock()
+	defer mapc.scanLock.Unlock()
+
+	if mapc.cfg.Cached {
+		mapc.lock.Lock()
+		scan := mapc.lastScan
+		mapc.lock.Unlock()
+
+		if !rescan && scan != nil && !mapc.cfg.tooOld(scan) {
+			return scan, nil
+		}
+	}
+
+	mapc.lock.Lock()
+	mapc.scanning = true
+	mapc.lock.Unlock()
+
+	scan, err := scanPositions(ctx, mapc.positions, mapc.cfg.sleepTime(), mapc.src, extra, mapc.fsSvc, scanOptions{
+		poseImages: mapc.cfg.PoseImages,
+		restore:    mapc.restore,
+		icp:        mapc.cfg.icpOptions(),
+	})
+
+	mapc.lock.Lock()
+	defer mapc.lock.Unlock()
+	mapc.scanning = false
+	if err != nil {
+		return nil, err
+	}
+	mapc.lastScan = scan
+
+	mapc.logger.Debugf("scanning %d positions took %v", len(mapc.positions), scan.duration)
+
+	return scan, nil
+}
+
+func (mapc *MultipleArmPosesCamera) status() map[string]interface{} {
+	mapc.lock.Lock()
+	defer mapc.lock.Unlock()
+
+	res := map[string]interface{}{
+		"cached":   mapc.cfg.Cached,
+		"scanning": mapc.scanning,
+		"has_scan": mapc.lastScan != nil,
+	}
+
+	if mapc.lastScan != nil {
+		posePoints := []interface{}{}
+		for _, n := range mapc.lastScan.poseSizes {
+			posePoints = append(posePoints, n)
+		}
+		res["age_seconds"] = mapc.lastScan.age().Seconds()
+		res["scan_seconds"] = mapc.lastScan.duration.Seconds()
+		res["pose_points"] = posePoints
+		res["total_points"] = mapc.lastScan.cloud.Size()
+		res["expired"] = mapc.cfg.tooOld(mapc.lastScan)
+		if mapc.cfg.RefineWithICP {
+			fitness := []interface{}{}
+			for _, f := range mapc.lastScan.icpFitness {
+				fitness = append(fitness, f)
+			}
+			res["icp_fitness"] = fitness
+		}
+	}
+
+	return res
 }
 
 func (mapc *MultipleArmPosesCamera) Properties(ctx context.Context) (camera.Properties, error) {
@@ -0,0 +1,201 @@
+package touch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils"
+)
+
+func newTestMultipleArmPoses(t *testing.T, cfg *MultipleArmPosesConfig, others ...resource.Resource) (*MultipleArmPosesCamera, *int) {
+	moves := 0
+
+	pos1 := inject.NewSwitch("pos1")
+	pos1.SetPositionFunc = func(ctx context.Context, position uint32, extra map[string]interface{}) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		moves++
+		return nil
+	}
+	pos2 := inject.NewSwitch("pos2")
+	pos2.SetPositionFunc = pos1.SetPositionFunc
+
+	src := inject.NewCamera("src")
+	src.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		pc := pointcloud.NewBasicEmpty()
+		err := pc.Set(r3.Vector{float64(moves), 0, 0}, nil)
+		return pc, err
+	}
+
+	fsSvc := inject.NewFrameSystemService(framesystem.PublicServiceName.Name)
+	fsSvc.GetPoseFunc = func(
+		ctx context.Context,
+		componentName, destinationFrame string,
+		supplementalTransforms []*referenceframe.LinkInFrame,
+		extra map[string]interface{},
+	) (*referenceframe.PoseInFrame, error) {
+		return referenceframe.NewPoseInFrame(referenceframe.World, spatialmath.NewZeroPose()), nil
+	}
+
+	deps := resource.Dependencies{
+		pos1.Name():  pos1,
+		pos2.Name():  pos2,
+		src.Name():   src,
+		fsSvc.Name(): fsSvc,
+	}
+	for _, r := range others {
+		deps[r.Name()] = r
+	}
+
+	cfg.Src = "src"
+	cfg.Positions = []string{"pos1", "pos2"}
+	cfg.SleepSeconds = .001
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	res, err := newMultipleArmPoses(context.Background(), deps, resource.Config{
+		Name:                "poses",
+		API:                 camera.API,
+		Model:               resource.Model{Family: vmodutils.NamespaceFamily},
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	return res.(*MultipleArmPosesCamera), &moves
+}
+
+func TestMultipleArmPosesCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("uncached moves every time", func(t *testing.T) {
+		cam, moves := newTestMultipleArmPoses(t, &MultipleArmPosesConfig{})
+
+		pc, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 2)
+		_, err = cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, *moves, test.ShouldEqual, 4)
+	})
+
+	t.Run("cached only moves on rescan", func(t *testing.T) {
+		cam, moves := newTestMultipleArmPoses(t, &MultipleArmPosesConfig{Cached: true})
+
+		status, err := cam.DoCommand(ctx, map[string]interface{}{"status": true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, status["has_scan"], test.ShouldBeFalse)
+
+		_, err = cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		_, err = cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, *moves, test.ShouldEqual, 2)
+
+		status, err = cam.DoCommand(ctx, map[string]interface{}{"rescan": true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, *moves, test.ShouldEqual, 4)
+		test.That(t, status["has_scan"], test.ShouldBeTrue)
+		test.That(t, status["total_points"], test.ShouldEqual, 2)
+		test.That(t, status["pose_points"], test.ShouldResemble, []interface{}{1, 1})
+		test.That(t, status["expired"], test.ShouldBeFalse)
+
+		res, err := cam.DoCommand(ctx, map[string]interface{}{"foo": true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, res, test.ShouldBeNil)
+	})
+
+	t.Run("icp that can't line up keeps the frame system pose", func(t *testing.T) {
+		cam, _ := newTestMultipleArmPoses(t, &MultipleArmPosesConfig{RefineWithICP: true})
+
+		pc, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+		status, err := cam.DoCommand(ctx, map[string]interface{}{"status": true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, status["icp_fitness"], test.ShouldResemble, []interface{}{0.0})
+	})
+
+	t.Run("images never move the arm", func(t *testing.T) {
+		cam, moves := newTestMultipleArmPoses(t, &MultipleArmPosesConfig{})
+
+		_, _, err := cam.Images(ctx, nil, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		_, _, err = cam.Image(ctx, "image/png", nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, *moves, test.ShouldEqual, 0)
+
+		_, err = cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, *moves, test.ShouldEqual, 2)
+
+		imgs, _, err := cam.Images(ctx, nil, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(imgs), test.ShouldEqual, 1)
+		test.That(t, imgs[0].SourceName, test.ShouldEqual, "merged")
+		_, _, err = cam.Image(ctx, "image/png", nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, *moves, test.ShouldEqual, 2)
+	})
+
+	t.Run("cached rescans once too old", func(t *testing.T) {
+		cam, moves := newTestMultipleArmPoses(t, &MultipleArmPosesConfig{Cached: true, MaxAgeSeconds: .000001})
+
+		_, err := cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		_, err = cam.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, *moves, test.ShouldEqual, 4)
+	})
+}
+
+func TestMultipleArmPosesRestore(t *testing.T) {
+	fakeArm := inject.NewArm("arm")
+	fakeArm.JointPositionsFunc = func(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+		return []referenceframe.Input{1, 2, 3}, nil
+	}
+	restored := [][]referenceframe.Input{}
+	fakeArm.MoveToJointPositionsFunc = func(ctx context.Context, joints []referenceframe.Input, extra map[string]interface{}) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		restored = append(restored, joints)
+		return nil
+	}
+
+	cfg := &MultipleArmPosesConfig{RestoreArm: "arm"}
+	cam, moves := newTestMultipleArmPoses(t, cfg, fakeArm)
+
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldContain, "arm")
+
+	_, err = cam.NextPointCloud(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, *moves, test.ShouldEqual, 2)
+	test.That(t, restored, test.ShouldResemble, [][]referenceframe.Input{{1, 2, 3}})
+
+	// canceled before the first move, the arm still goes back
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cam.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, *moves, test.ShouldEqual, 2)
+	test.That(t, len(restored), test.ShouldEqual, 2)
+
+	_, _, err = (&MultipleArmPosesConfig{Src: "src", Positions: []string{"a"}, RestoreMotion: "builtin"}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
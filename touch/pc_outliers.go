@@ -0,0 +1,177 @@
+package touch
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// OutlierFilterConfig is the optional "outlier_filter" block on cameras.
+// The statistical filter runs if mean_k is set, the radius filter runs if radius is set.
+type OutlierFilterConfig struct {
+	MeanK      int     `json:"mean_k,omitempty"`
+	StddevMult float64 `json:"stddev_mult,omitempty"`
+
+	Radius       float64 `json:"radius,omitempty"`
+	MinNeighbors int     `json:"min_neighbors,omitempty"`
+}
+
+func (c *OutlierFilterConfig) Validate() error {
+	if c.MeanK < 0 || c.StddevMult < 0 || c.Radius < 0 || c.MinNeighbors < 0 {
+		return fmt.Errorf("outlier_filter values cannot be negative")
+	}
+	if c.MeanK == 0 && c.Radius == 0 {
+		return fmt.Errorf("outlier_filter needs mean_k or radius")
+	}
+	if c.Radius > 0 && c.MinNeighbors == 0 {
+		return fmt.Errorf("outlier_filter needs min_neighbors when radius is set")
+	}
+	return nil
+}
+
+func (c *OutlierFilterConfig) stddevMult() float64 {
+	if c.StddevMult <= 0 {
+		return 1
+	}
+	return c.StddevMult
+}
+
+func (c *OutlierFilterConfig) Apply(pc pointcloud.PointCloud) (pointcloud.PointCloud, error) {
+	var err error
+
+	if c.MeanK > 0 {
+		pc, err = PCRemoveStatisticalOutliers(pc, c.MeanK, c.stddevMult())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Radius > 0 {
+		pc, err = PCRemoveRadiusOutliers(pc, c.Radius, c.MinNeighbors)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pc, nil
+}
+
+// PCRemoveStatisticalOutliers drops points whose mean distance to their k nearest neighbors
+// is more than stddevMult standard deviations above the mean for the whole cloud.
+func PCRemoveStatisticalOutliers(pc pointcloud.PointCloud, k int, stddevMult float64) (pointcloud.PointCloud, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive, got %d", k)
+	}
+	if stddevMult <= 0 {
+		return nil, fmt.Errorf("stddevMult must be positive, got %v", stddevMult)
+	}
+
+	kd := pointcloud.ToKDTree(pc)
+
+	points := make([]pointcloud.PointAndData, 0, kd.Size())
+	avgDistances := make([]float64, 0, kd.Size())
+
+	kd.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		neighbors := kd.KNearestNeighbors(p, k, false)
+		sum := 0.0
+		for _, n := range neighbors {
+			sum += p.Distance(n.P)
+		}
+		avg := 0.0
+		if len(neighbors) > 0 {
+			avg = sum / float64(len(neighbors))
+		}
+		points = append(points, pointcloud.PointAndData{P: p, D: d})
+		avgDistances = append(avgDistances, avg)
+		return true
+	})
+
+	if len(points) == 0 {
+		return pointcloud.NewBasicEmpty(), nil
+	}
+
+	mean := 0.0
+	for _, d := range avgDistances {
+		mean += d
+	}
+	mean /= float64(len(avgDistances))
+
+	variance := 0.0
+	for _, d := range avgDistances {
+		variance += (d - mean) * (d - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(avgDistances)))
+
+	threshold := mean + stddevMult*stddev
+
+	out := pointcloud.NewBasicPointCloud(len(points))
+	for i, p := range points {
+		if avgDistances[i] > threshold {
+			continue
+		}
+		err := out.Set(p.P, p.D)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// PCRemoveRadiusOutliers drops points with fewer than minNeighbors other points within radius.
+func PCRemoveRadiusOutliers(pc pointcloud.PointCloud, radius float64, minNeighbors int) (pointcloud.PointCloud, error) {
+	if radius <= 0 {
+		return nil, fmt.Errorf("radius must be positive, got %v", radius)
+	}
+
+	if minNeighbors <= 0 {
+		return pc, nil
+	}
+
+	// bucket on a grid with cells the size of the radius, so all neighbors are in the 27 surrounding cells
+	cells := map[voxelKey][]r3.Vector{}
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		k := newVoxelKey(p, radius)
+		cells[k] = append(cells[k], p)
+		return true
+	})
+
+	r2 := radius * radius
+
+	out := pointcloud.NewBasicPointCloud(pc.Size())
+
+	var err error
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		k := newVoxelKey(p, radius)
+
+		// this counts the point itself
+		count := 0
+		for dx := int64(-1); dx <= 1 && count <= minNeighbors; dx++ {
+			for dy := int64(-1); dy <= 1 && count <= minNeighbors; dy++ {
+				for dz := int64(-1); dz <= 1 && count <= minNeighbors; dz++ {
+					for _, o := range cells[voxelKey{k.x + dx, k.y + dy, k.z + dz}] {
+						if p.Sub(o).Norm2() <= r2 {
+							count++
+							if count > minNeighbors {
+								break
+							}
+						}
+					}
+				}
+			}
+		}
+
+		if count > minNeighbors {
+			err = out.Set(p, d)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
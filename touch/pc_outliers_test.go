@@ -0,0 +1,73 @@
+package touch
+
+import (
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+)
+
+func makeNoisyGrid(t testing.TB) pointcloud.PointCloud {
+	pc := pointcloud.NewBasicEmpty()
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			test.That(t, pc.Set(r3.Vector{float64(x), float64(y), 0}, pointcloud.NewBasicData()), test.ShouldBeNil)
+		}
+	}
+	test.That(t, pc.Set(r3.Vector{10, 10, 100}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{-50, 10, 0}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	return pc
+}
+
+func TestPCRemoveStatisticalOutliers(t *testing.T) {
+	in := makeNoisyGrid(t)
+
+	out, err := PCRemoveStatisticalOutliers(in, 8, 1)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 400)
+	test.That(t, pointcloud.CloudContains(out, 10, 10, 100), test.ShouldBeFalse)
+	test.That(t, pointcloud.CloudContains(out, -50, 10, 0), test.ShouldBeFalse)
+
+	_, err = PCRemoveStatisticalOutliers(in, 0, 1)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPCRemoveRadiusOutliers(t *testing.T) {
+	in := makeNoisyGrid(t)
+
+	out, err := PCRemoveRadiusOutliers(in, 1.5, 3)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 400)
+	test.That(t, pointcloud.CloudContains(out, 10, 10, 100), test.ShouldBeFalse)
+	test.That(t, pointcloud.CloudContains(out, 0, 0, 0), test.ShouldBeTrue)
+
+	// corners only have 3 neighbors within 1.5
+	out, err = PCRemoveRadiusOutliers(in, 1.5, 4)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 396)
+}
+
+func TestOutlierFilterConfig(t *testing.T) {
+	test.That(t, (&OutlierFilterConfig{}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&OutlierFilterConfig{Radius: 5}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&OutlierFilterConfig{MeanK: 10}).Validate(), test.ShouldBeNil)
+
+	cfg := &OutlierFilterConfig{MeanK: 8, Radius: 1.5, MinNeighbors: 4}
+	test.That(t, cfg.Validate(), test.ShouldBeNil)
+	out, err := cfg.Apply(makeNoisyGrid(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 396)
+}
+
+func BenchmarkPCRemoveRadiusOutliers(t *testing.B) {
+	in, err := pointcloud.NewFromFile("data/cup1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	t.ResetTimer()
+	for range t.N {
+		_, err := PCRemoveRadiusOutliers(in, 5, 5)
+		test.That(t, err, test.ShouldBeNil)
+	}
+}
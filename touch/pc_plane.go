@@ -0,0 +1,343 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/erh/vmodutils"
+)
+
+var RemovePlaneCameraModel = vmodutils.NamespaceFamily.WithModel("pc-remove-plane-camera")
+
+func init() {
+	resource.RegisterComponent(
+		camera.API,
+		RemovePlaneCameraModel,
+		resource.Registration[camera.Camera, *RemovePlaneCameraConfig]{
+			Constructor: newRemovePlaneCamera,
+		})
+}
+
+// ErrNoPlane is what PCSegmentPlane returns when the cloud doesn't have a plane in it.
+var ErrNoPlane = errors.New("no plane found")
+
+// PCSegmentPlane finds the plane with the most points within distThreshold using RANSAC.
+// Returns the plane, the points on it, and everything else.
+func PCSegmentPlane(pc pointcloud.PointCloud, distThreshold float64, iterations int) (pointcloud.Plane, pointcloud.PointCloud, pointcloud.PointCloud, error) {
+	return PCSegmentPlaneWithNormal(pc, distThreshold, iterations, r3.Vector{}, 0)
+}
+
+// PCSegmentPlaneWithNormal is PCSegmentPlane, but only considers planes whose normal is within
+// maxAngleDegrees of normal. A zero normal means any plane is ok.
+func PCSegmentPlaneWithNormal(
+	pc pointcloud.PointCloud,
+	distThreshold float64,
+	iterations int,
+	normal r3.Vector,
+	maxAngleDegrees float64,
+) (pointcloud.Plane, pointcloud.PointCloud, pointcloud.PointCloud, error) {
+	if distThreshold <= 0 {
+		return nil, nil, nil, fmt.Errorf("distThreshold must be positive, got %v", distThreshold)
+	}
+	if iterations <= 0 {
+		return nil, nil, nil, fmt.Errorf("iterations must be positive, got %d", iterations)
+	}
+
+	points := make([]r3.Vector, 0, pc.Size())
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		points = append(points, p)
+		return true
+	})
+
+	if len(points) < 3 {
+		return nil, nil, nil, fmt.Errorf("%w, need at least 3 points, have %d", ErrNoPlane, len(points))
+	}
+
+	checkNormal := normal.Norm2() > 0
+	minCos := 0.0
+	if checkNormal {
+		normal = normal.Normalize()
+		minCos = math.Cos(maxAngleDegrees * math.Pi / 180)
+	}
+
+	// fixed seed so the same cloud gives the same answer
+	r := rand.New(rand.NewSource(1))
+
+	bestCount := 0
+	var bestNormal r3.Vector
+	var bestOffset float64
+
+	for range iterations {
+		a := points[r.Intn(len(points))]
+		b := points[r.Intn(len(points))]
+		c := points[r.Intn(len(points))]
+
+		n := b.Sub(a).Cross(c.Sub(a))
+		if n.Norm2() < 1e-9 {
+			continue
+		}
+		n = n.Normalize()
+
+		if checkNormal && math.Abs(n.Dot(normal)) < minCos {
+			continue
+		}
+
+		offset := -n.Dot(a)
+
+		count := 0
+		for _, p := range points {
+			if math.Abs(n.Dot(p)+offset) <= distThreshold {
+				count++
+			}
+		}
+
+		if count > bestCount {
+			bestCount = count
+			bestNormal = n
+			bestOffset = offset
+		}
+	}
+
+	if bestCount == 0 {
+		return nil, nil, nil, fmt.Errorf("%w after %d iterations", ErrNoPlane, iterations)
+	}
+
+	inliers := pointcloud.NewBasicPointCloud(bestCount)
+	outliers := pointcloud.NewBasicPointCloud(len(points) - bestCount)
+
+	var err error
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if math.Abs(bestNormal.Dot(p)+bestOffset) <= distThreshold {
+			err = inliers.Set(p, d)
+		} else {
+			err = outliers.Set(p, d)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	plane := pointcloud.NewPlaneWithCenter(
+		inliers,
+		[4]float64{bestNormal.X, bestNormal.Y, bestNormal.Z, bestOffset},
+		pointcloud.CloudCentroid(inliers),
+	)
+
+	return plane, inliers, outliers, nil
+}
+
+type RemovePlaneCameraConfig struct {
+	Src      string
+	SrcFrame string `json:"src_frame"`
+
+	DistanceThreshold float64 `json:"distance_threshold_mm"`
+	Iterations        int
+
+	// Normal is in the world frame, so {"Z": 1} is a table
+	Normal                 *r3.Vector
+	NormalToleranceDegrees float64 `json:"normal_tolerance_degrees"`
+
+	// Render controls how Image and Images draw the point cloud
+	Render *RenderOptions `json:"render,omitempty"`
+}
+
+func (c *RemovePlaneCameraConfig) Validate(path string) ([]string, []string, error) {
+	if c.Src == "" {
+		return nil, nil, fmt.Errorf("need a src camera")
+	}
+	if c.Render != nil {
+		err := c.Render.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.DistanceThreshold < 0 || c.Iterations < 0 {
+		return nil, nil, fmt.Errorf("distance_threshold_mm and iterations cannot be negative")
+	}
+	if c.Normal != nil && c.Normal.Norm2() == 0 {
+		return nil, nil, fmt.Errorf("normal cannot be zero")
+	}
+	return []string{c.Src}, nil, nil
+}
+
+func (c *RemovePlaneCameraConfig) distanceThreshold() float64 {
+	if c.DistanceThreshold <= 0 {
+		return 5
+	}
+	return c.DistanceThreshold
+}
+
+func (c *RemovePlaneCameraConfig) iterations() int {
+	if c.Iterations <= 0 {
+		return 200
+	}
+	return c.Iterations
+}
+
+func (c *RemovePlaneCameraConfig) normalToleranceDegrees() float64 {
+	if c.NormalToleranceDegrees <= 0 {
+		return 10
+	}
+	return c.NormalToleranceDegrees
+}
+
+func (c *RemovePlaneCameraConfig) srcFrame() string {
+	if c.SrcFrame != "" {
+		return c.SrcFrame
+	}
+	return c.Src
+}
+
+func newRemovePlaneCamera(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (camera.Camera, error) {
+	newConf, err := resource.NativeConfig[*RemovePlaneCameraConfig](config)
+	if err != nil {
+		return nil, err
+	}
+
+	rpc := &removePlaneCamera{
+		name:   config.ResourceName(),
+		cfg:    newConf,
+		logger: logger,
+	}
+
+	rpc.src, err = camera.FromProvider(deps, newConf.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	if newConf.Normal != nil {
+		rpc.fsSvc, err = framesystem.FromDependencies(deps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rpc, nil
+}
+
+type removePlaneCamera struct {
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	name   resource.Name
+	cfg    *RemovePlaneCameraConfig
+	logger logging.Logger
+
+	src   camera.Camera
+	fsSvc framesystem.Service
+}
+
+func (rpc *removePlaneCamera) Name() resource.Name {
+	return rpc.name
+}
+
+func (rpc *removePlaneCamera) Image(ctx context.Context, mimeType string, extra map[string]interface{}) ([]byte, camera.ImageMetadata, error) {
+	pc, err := rpc.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	img, err := PCToImageWithOptions(pc, rpc.cfg.Render)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+
+	data, err := rimage.EncodeImage(ctx, img, mimeType)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+
+	return data, camera.ImageMetadata{MimeType: mimeType}, err
+}
+
+func (rpc *removePlaneCamera) Images(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	pc, err := rpc.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	img, err := PCToImageWithOptions(pc, rpc.cfg.Render)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+
+	ni, err := camera.NamedImageFromImage(img, "cropped", "image/png", data.Annotations{})
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	return []camera.NamedImage{ni}, resource.ResponseMetadata{time.Now()}, nil
+}
+
+func (rpc *removePlaneCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+// normalInSrcFrame rotates the configured world normal into the frame of the src camera.
+func (rpc *removePlaneCamera) normalInSrcFrame(ctx context.Context) (r3.Vector, error) {
+	if rpc.cfg.Normal == nil {
+		return r3.Vector{}, nil
+	}
+
+	pif, err := rpc.fsSvc.GetPose(ctx, rpc.cfg.srcFrame(), referenceframe.World, nil, nil)
+	if err != nil {
+		return r3.Vector{}, err
+	}
+
+	inverse := spatialmath.PoseInverse(spatialmath.NewPoseFromOrientation(pif.Pose().Orientation()))
+	return spatialmath.Compose(inverse, spatialmath.NewPoseFromPoint(*rpc.cfg.Normal)).Point(), nil
+}
+
+func (rpc *removePlaneCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	pc, err := rpc.src.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	normal, err := rpc.normalInSrcFrame(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	_, _, outliers, err := PCSegmentPlaneWithNormal(pc, rpc.cfg.distanceThreshold(), rpc.cfg.iterations(), normal, rpc.cfg.normalToleranceDegrees())
+	if errors.Is(err, ErrNoPlane) {
+		// an empty crop or the table out of view, there's nothing to remove
+		rpc.logger.Debugf("not removing a plane: %v", err)
+		return pc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := time.Since(start)
+	if elapsed > (time.Millisecond * 100) {
+		rpc.logger.Infof("PCSegmentPlane took %v", elapsed)
+	}
+
+	return outliers, nil
+}
+
+func (rpc *removePlaneCamera) Properties(ctx context.Context) (camera.Properties, error) {
+	return camera.Properties{
+		SupportsPCD: true,
+	}, nil
+}
+
+func (rpc *removePlaneCamera) Geometries(ctx context.Context, _ map[string]interface{}) ([]spatialmath.Geometry, error) {
+	return nil, nil
+}
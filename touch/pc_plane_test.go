@@ -0,0 +1,118 @@
+package touch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/test"
+)
+
+func makeTableWithBox(t testing.TB) pointcloud.PointCloud {
+	pc := pointcloud.NewBasicEmpty()
+	// table at z=0, 50x50 points
+	for x := 0; x < 50; x++ {
+		for y := 0; y < 50; y++ {
+			z := float64((x+y)%3) * .5
+			test.That(t, pc.Set(r3.Vector{float64(x * 4), float64(y * 4), z}, pointcloud.NewBasicData()), test.ShouldBeNil)
+		}
+	}
+	// a 10x10x10 box of points sitting on top
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			for z := 1; z <= 10; z++ {
+				test.That(t, pc.Set(r3.Vector{float64(80 + x*2), float64(80 + y*2), float64(10 + z*2)}, pointcloud.NewBasicData()), test.ShouldBeNil)
+			}
+		}
+	}
+	return pc
+}
+
+func TestPCSegmentPlane(t *testing.T) {
+	in := makeTableWithBox(t)
+
+	plane, inliers, outliers, err := PCSegmentPlane(in, 2, 100)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, inliers.Size(), test.ShouldEqual, 2500)
+	test.That(t, outliers.Size(), test.ShouldEqual, 1000)
+	test.That(t, plane.Normal().Z, test.ShouldNotAlmostEqual, 0)
+
+	_, _, _, err = PCSegmentPlane(pointcloud.NewBasicEmpty(), 2, 100)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPCSegmentPlaneWithNormal(t *testing.T) {
+	in := makeTableWithBox(t)
+
+	// add a wall at x = -10
+	for y := 0; y < 50; y++ {
+		for z := 1; z < 20; z++ {
+			test.That(t, in.Set(r3.Vector{-10, float64(y * 4), float64(z * 4)}, pointcloud.NewBasicData()), test.ShouldBeNil)
+		}
+	}
+
+	// the table is the biggest plane, but we only want vertical ones
+	_, inliers, _, err := PCSegmentPlaneWithNormal(in, 1, 500, r3.Vector{X: 1}, 5)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, inliers.Size(), test.ShouldEqual, 950)
+	inliers.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		test.That(t, p.X, test.ShouldEqual, -10)
+		return true
+	})
+}
+
+func TestRemovePlaneCameraNoPlane(t *testing.T) {
+	ctx := context.Background()
+
+	var cloud pointcloud.PointCloud
+	src := inject.NewCamera("src")
+	src.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		return cloud, nil
+	}
+	fsSvc := inject.NewFrameSystemService(framesystem.PublicServiceName.Name)
+	fsSvc.GetPoseFunc = func(
+		ctx context.Context,
+		componentName, destinationFrame string,
+		supplementalTransforms []*referenceframe.LinkInFrame,
+		extra map[string]interface{},
+	) (*referenceframe.PoseInFrame, error) {
+		return referenceframe.NewPoseInFrame(destinationFrame, spatialmath.NewZeroPose()), nil
+	}
+
+	rpc := &removePlaneCamera{
+		cfg:    &RemovePlaneCameraConfig{Src: "src", Normal: &r3.Vector{Z: 1}},
+		logger: logging.NewTestLogger(t),
+		src:    src,
+		fsSvc:  fsSvc,
+	}
+
+	// an empty crop
+	cloud = pointcloud.NewBasicEmpty()
+	out, err := rpc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 0)
+
+	// only a wall, the table is out of view
+	cloud = pointcloud.NewBasicEmpty()
+	for y := 0; y < 20; y++ {
+		for z := 0; z < 20; z++ {
+			test.That(t, cloud.Set(r3.Vector{Y: float64(y * 4), Z: float64(z * 4)}, nil), test.ShouldBeNil)
+		}
+	}
+	out, err = rpc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 400)
+
+	// with the table it's removed
+	cloud = makeTableWithBox(t)
+	out, err = rpc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 1000)
+}
@@ -0,0 +1,349 @@
+package touch
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+const (
+	ProjectionTop   = "top"
+	ProjectionFront = "front"
+	ProjectionSide  = "side"
+	ProjectionPose  = "pose"
+)
+
+// maxRenderSize keeps a big cloud or a bad scale from allocating a giant image.
+const maxRenderSize = 10000
+
+// RenderOptions control how a point cloud is turned into an image. The zero value is what PCToImage does.
+type RenderOptions struct {
+	// Projection is the plane the cloud is projected on:
+	// top (default) looks down -Z with +X right and +Y down the image,
+	// front looks along +Y with +X right and +Z up,
+	// side looks along -X with +Y right and +Z up,
+	// pose looks along the Z axis of Orientation with its X axis right and Y axis down.
+	Projection string `json:"projection,omitempty"`
+
+	// for pose, points behind Point are dropped
+	Point       *r3.Vector                            `json:"point,omitempty"`
+	Orientation *spatialmath.OrientationVectorDegrees `json:"orientation,omitempty"`
+
+	// PixelsPerMM is the scale, defaults to 1
+	PixelsPerMM float64 `json:"pixels_per_mm,omitempty"`
+	// Width, if set, picks the scale so the image is this wide
+	Width int `json:"width,omitempty"`
+
+	// DepthColormap colors points by depth (red is closest) instead of their own color
+	DepthColormap bool `json:"depth_colormap,omitempty"`
+
+	// FillHoles is how many passes of hole filling to do, each fills empty pixels mostly surrounded by points
+	FillHoles int `json:"fill_holes,omitempty"`
+}
+
+func (ro *RenderOptions) Validate() error {
+	switch ro.Projection {
+	case "", ProjectionTop, ProjectionFront, ProjectionSide:
+	case ProjectionPose:
+		if ro.Orientation == nil {
+			return fmt.Errorf("pose projection needs an orientation")
+		}
+	default:
+		return fmt.Errorf("unknown projection [%s]", ro.Projection)
+	}
+	if ro.PixelsPerMM < 0 || ro.Width < 0 || ro.FillHoles < 0 {
+		return fmt.Errorf("pixels_per_mm, width, and fill_holes cannot be negative")
+	}
+	return nil
+}
+
+// axes returns the directions for image right, image down, and depth (away from the viewer),
+// and the origin depth is measured from.
+func (ro *RenderOptions) axes() (r3.Vector, r3.Vector, r3.Vector, r3.Vector) {
+	switch ro.Projection {
+	case ProjectionFront:
+		return r3.Vector{X: 1}, r3.Vector{Z: -1}, r3.Vector{Y: 1}, r3.Vector{}
+	case ProjectionSide:
+		return r3.Vector{Y: 1}, r3.Vector{Z: -1}, r3.Vector{X: -1}, r3.Vector{}
+	case ProjectionPose:
+		rot := spatialmath.NewPoseFromOrientation(ro.Orientation)
+		axis := func(v r3.Vector) r3.Vector {
+			return spatialmath.Compose(rot, spatialmath.NewPoseFromPoint(v)).Point()
+		}
+		origin := r3.Vector{}
+		if ro.Point != nil {
+			origin = *ro.Point
+		}
+		return axis(r3.Vector{X: 1}), axis(r3.Vector{Y: 1}), axis(r3.Vector{Z: 1}), origin
+	default:
+		return r3.Vector{X: 1}, r3.Vector{Y: 1}, r3.Vector{Z: -1}, r3.Vector{}
+	}
+}
+
+type renderPoint struct {
+	u, v, depth float64
+	c           color.Color
+}
+
+// PCToImageWithOptions renders pc with an orthographic projection, keeping the point closest to the viewer
+// for each pixel. nil options are the defaults. Images are never more than 10000 pixels on a side.
+func PCToImageWithOptions(pc pointcloud.PointCloud, opts *RenderOptions) (image.Image, error) {
+	if opts == nil {
+		opts = &RenderOptions{}
+	}
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	right, down, forward, origin := opts.axes()
+	checkBehind := opts.Projection == ProjectionPose && opts.Point != nil
+
+	points := make([]renderPoint, 0, pc.Size())
+	minU, minV, minDepth := math.Inf(1), math.Inf(1), math.Inf(1)
+	maxU, maxV, maxDepth := math.Inf(-1), math.Inf(-1), math.Inf(-1)
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		rel := p.Sub(origin)
+		rp := renderPoint{u: rel.Dot(right), v: rel.Dot(down), depth: rel.Dot(forward)}
+		if checkBehind && rp.depth < 0 {
+			return true
+		}
+		if d != nil && d.HasColor() {
+			rp.c = d.Color()
+		} else {
+			rp.c = color.White
+		}
+		points = append(points, rp)
+
+		minU, maxU = math.Min(minU, rp.u), math.Max(maxU, rp.u)
+		minV, maxV = math.Min(minV, rp.v), math.Max(maxV, rp.v)
+		minDepth, maxDepth = math.Min(minDepth, rp.depth), math.Max(maxDepth, rp.depth)
+		return true
+	})
+
+	if len(points) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 1, 1)), nil
+	}
+
+	scale := opts.PixelsPerMM
+	if opts.Width > 0 {
+		scale = float64(opts.Width-1) / math.Max(maxU-minU, 1e-9)
+	}
+	if scale <= 0 {
+		scale = 1
+	}
+
+	span := math.Max(maxU-minU, maxV-minV)
+	if span*scale >= maxRenderSize {
+		scale = float64(maxRenderSize-1) / span
+	}
+
+	width := int(math.Floor((maxU-minU)*scale)) + 1
+	height := int(math.Floor((maxV-minV)*scale)) + 1
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	zbuf := make([]float64, width*height)
+	for i := range zbuf {
+		zbuf[i] = math.Inf(1)
+	}
+
+	depthColor := func(depth float64) color.Color {
+		t := 0.0
+		if maxDepth > minDepth {
+			t = (depth - minDepth) / (maxDepth - minDepth)
+		}
+		return jetColor(1 - t)
+	}
+
+	for _, rp := range points {
+		x := int(math.Floor((rp.u - minU) * scale))
+		y := int(math.Floor((rp.v - minV) * scale))
+		key := y*width + x
+		if rp.depth >= zbuf[key] {
+			continue
+		}
+		zbuf[key] = rp.depth
+		if opts.DepthColormap {
+			img.Set(x, y, depthColor(rp.depth))
+		} else {
+			img.Set(x, y, rp.c)
+		}
+	}
+
+	for range opts.FillHoles {
+		if !fillHoles(img, zbuf, width, height) {
+			break
+		}
+	}
+
+	return img, nil
+}
+
+// PCToImageWithIntrinsics renders pc, which has to be in the camera's frame, through the camera's intrinsics
+// so it lines up pixel for pixel with the camera's own images. Returns a color image and a depth map (mm),
+// both at the camera's resolution, keeping the closest point for each pixel.
+func PCToImageWithIntrinsics(pc pointcloud.PointCloud, props camera.Properties) (image.Image, *rimage.DepthMap, error) {
+	intrinsics := props.IntrinsicParams
+	if intrinsics == nil {
+		return nil, nil, fmt.Errorf("intrinsics cannot be null")
+	}
+	if intrinsics.Width <= 0 || intrinsics.Height <= 0 {
+		return nil, nil, fmt.Errorf("intrinsics need a width and height, got %dx%d", intrinsics.Width, intrinsics.Height)
+	}
+
+	width, height := intrinsics.Width, intrinsics.Height
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	dm := rimage.NewEmptyDepthMap(width, height)
+
+	zbuf := make([]float64, width*height)
+	for i := range zbuf {
+		zbuf[i] = math.Inf(1)
+	}
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if p.Z <= 0 {
+			return true
+		}
+
+		fx, fy := intrinsics.PointToPixel(p.X, p.Y, p.Z)
+		x, y := int(fx), int(fy)
+		if x < 0 || y < 0 || x >= width || y >= height {
+			return true
+		}
+
+		key := y*width + x
+		if p.Z >= zbuf[key] {
+			return true
+		}
+		zbuf[key] = p.Z
+
+		if d != nil && d.HasColor() {
+			img.Set(x, y, d.Color())
+		} else {
+			img.Set(x, y, color.White)
+		}
+		dm.Set(x, y, rimage.Depth(math.Min(p.Z, math.MaxUint16)))
+		return true
+	})
+
+	return img, dm, nil
+}
+
+// intrinsicsNamedImages is PCToImageWithIntrinsics as "aligned" and "depth" named images,
+// skipping any not in filterSourceNames if it isn't empty.
+func intrinsicsNamedImages(pc pointcloud.PointCloud, props camera.Properties, filterSourceNames []string) ([]camera.NamedImage, error) {
+	img, dm, err := PCToImageWithIntrinsics(pc, props)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []camera.NamedImage{}
+
+	if wantSourceName(filterSourceNames, "aligned") {
+		ni, err := camera.NamedImageFromImage(img, "aligned", utils.MimeTypePNG, data.Annotations{})
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ni)
+	}
+
+	if wantSourceName(filterSourceNames, "depth") {
+		ni, err := camera.NamedImageFromImage(dm, "depth", utils.MimeTypeRawDepth, data.Annotations{})
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ni)
+	}
+
+	return res, nil
+}
+
+func wantSourceName(filterSourceNames []string, name string) bool {
+	if len(filterSourceNames) == 0 {
+		return true
+	}
+	for _, n := range filterSourceNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fillHoles fills empty pixels that have at least 5 of their 8 neighbors set with the closest neighbor.
+// Returns false if nothing was filled.
+func fillHoles(img *image.RGBA, zbuf []float64, width, height int) bool {
+	type fill struct {
+		x, y  int
+		depth float64
+		c     color.Color
+	}
+
+	fills := []fill{}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if !math.IsInf(zbuf[y*width+x], 1) {
+				continue
+			}
+
+			count := 0
+			best := fill{x, y, math.Inf(1), nil}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if (dx == 0 && dy == 0) || nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					d := zbuf[ny*width+nx]
+					if math.IsInf(d, 1) {
+						continue
+					}
+					count++
+					if d < best.depth {
+						best.depth = d
+						best.c = img.At(nx, ny)
+					}
+				}
+			}
+
+			if count >= 5 {
+				fills = append(fills, best)
+			}
+		}
+	}
+
+	// set after looking so a pass doesn't feed on itself
+	for _, f := range fills {
+		zbuf[f.y*width+f.x] = f.depth
+		img.Set(f.x, f.y, f.c)
+	}
+
+	return len(fills) > 0
+}
+
+// jetColor maps 0-1 to blue through green to red.
+func jetColor(t float64) color.Color {
+	clamp := func(v float64) uint8 {
+		return uint8(255 * math.Max(0, math.Min(1, v)))
+	}
+	return color.RGBA{
+		R: clamp(1.5 - math.Abs(4*t-3)),
+		G: clamp(1.5 - math.Abs(4*t-2)),
+		B: clamp(1.5 - math.Abs(4*t-1)),
+		A: 255,
+	}
+}
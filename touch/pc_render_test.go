@@ -0,0 +1,140 @@
+package touch
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+var (
+	renderRed  = color.NRGBA{255, 0, 0, 255}
+	renderBlue = color.NRGBA{0, 0, 255, 255}
+)
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+func TestPCToImageZBuffer(t *testing.T) {
+	pc := pointcloud.NewBasicEmpty()
+	// the blue point is above the red one, order should not matter
+	test.That(t, pc.Set(r3.Vector{10, 10, 100}, pointcloud.NewColoredData(renderBlue)), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{10, 10, 0}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{0, 0, 50}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{50, 20, 50}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+
+	img, err := PCToImageWithOptions(pc, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 51)
+	test.That(t, img.Bounds().Dy(), test.ShouldEqual, 21)
+	test.That(t, sameColor(img.At(10, 10), renderBlue), test.ShouldBeTrue)
+
+	// from the front, z is up so the blue point is at the top
+	img, err = PCToImageWithOptions(pc, &RenderOptions{Projection: ProjectionFront})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 51)
+	test.That(t, img.Bounds().Dy(), test.ShouldEqual, 101)
+	test.That(t, sameColor(img.At(10, 0), renderBlue), test.ShouldBeTrue)
+	test.That(t, sameColor(img.At(10, 100), renderRed), test.ShouldBeTrue)
+
+	img, err = PCToImageWithOptions(pc, &RenderOptions{Width: 200})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 200)
+
+	img, err = PCToImageWithOptions(pc, &RenderOptions{PixelsPerMM: .1})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 6)
+
+	img, err = PCToImageWithOptions(pc, &RenderOptions{DepthColormap: true})
+	test.That(t, err, test.ShouldBeNil)
+	r, _, b, _ := img.At(10, 10).RGBA()
+	test.That(t, r, test.ShouldBeGreaterThan, b)
+
+	img, err = PCToImageWithOptions(pointcloud.NewBasicEmpty(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 1)
+}
+
+func TestPCToImagePose(t *testing.T) {
+	pc := pointcloud.NewBasicEmpty()
+	test.That(t, pc.Set(r3.Vector{0, 0, 0}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{20, 0, 0}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{0, 0, 20}, pointcloud.NewColoredData(renderBlue)), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{-100, 0, 0}, pointcloud.NewColoredData(renderBlue)), test.ShouldBeNil)
+
+	// looking along +X from x=-50, so the point at -100 is behind
+	img, err := PCToImageWithOptions(pc, &RenderOptions{
+		Projection:  ProjectionPose,
+		Point:       &r3.Vector{X: -50},
+		Orientation: &spatialmath.OrientationVectorDegrees{OX: 1},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	// only the 0 and 20 in z are left, and the two red points are on top of each other
+	test.That(t, img.Bounds().Dx()*img.Bounds().Dy(), test.ShouldEqual, 21)
+
+	test.That(t, (&RenderOptions{Projection: ProjectionPose}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&RenderOptions{Projection: "bottom"}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&RenderOptions{Width: -1}).Validate(), test.ShouldNotBeNil)
+}
+
+func TestPCToImageFillHoles(t *testing.T) {
+	pc := pointcloud.NewBasicEmpty()
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			if x == 5 && y == 5 {
+				continue
+			}
+			test.That(t, pc.Set(r3.Vector{float64(x), float64(y), 0}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+		}
+	}
+
+	img, err := PCToImageWithOptions(pc, nil)
+	test.That(t, err, test.ShouldBeNil)
+	_, _, _, a := img.At(5, 5).RGBA()
+	test.That(t, a, test.ShouldEqual, 0)
+
+	img, err = PCToImageWithOptions(pc, &RenderOptions{FillHoles: 1})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, sameColor(img.At(5, 5), renderRed), test.ShouldBeTrue)
+	// the outside is not grown
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 10)
+}
+
+func TestPCToImageWithIntrinsics(t *testing.T) {
+	pc := pointcloud.NewBasicEmpty()
+	test.That(t, pc.Set(r3.Vector{0, 0, 1000}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{0, 0, 500}, pointcloud.NewColoredData(renderBlue)), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{100, 50, 1000}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+	// behind the camera
+	test.That(t, pc.Set(r3.Vector{0, 0, -1000}, pointcloud.NewColoredData(renderRed)), test.ShouldBeNil)
+
+	img, dm, err := PCToImageWithIntrinsics(pc, RealSenseProperties)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 1280)
+	test.That(t, img.Bounds().Dy(), test.ShouldEqual, 720)
+	test.That(t, dm.Width(), test.ShouldEqual, 1280)
+
+	x, y := RealSenseProperties.IntrinsicParams.PointToPixel(0, 0, 500)
+	test.That(t, sameColor(img.At(int(x), int(y)), renderBlue), test.ShouldBeTrue)
+	test.That(t, int(dm.GetDepth(int(x), int(y))), test.ShouldEqual, 500)
+
+	x, y = RealSenseProperties.IntrinsicParams.PointToPixel(100, 50, 1000)
+	test.That(t, sameColor(img.At(int(x), int(y)), renderRed), test.ShouldBeTrue)
+	test.That(t, int(dm.GetDepth(int(x), int(y))), test.ShouldEqual, 1000)
+
+	images, err := intrinsicsNamedImages(pc, RealSenseProperties, []string{"depth"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 1)
+	test.That(t, images[0].SourceName, test.ShouldEqual, "depth")
+
+	_, _, err = PCToImageWithIntrinsics(pc, camera.Properties{})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,292 @@
+package touch
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/erh/vmodutils"
+)
+
+var VoxelCameraModel = vmodutils.NamespaceFamily.WithModel("pc-voxel-camera")
+
+func init() {
+	resource.RegisterComponent(
+		camera.API,
+		VoxelCameraModel,
+		resource.Registration[camera.Camera, *VoxelCameraConfig]{
+			Constructor: newVoxelCamera,
+		})
+}
+
+type VoxelMode string
+
+const (
+	// VoxelModeCentroid keeps one point per voxel at the centroid of its points, with the data of the first point
+	VoxelModeCentroid VoxelMode = "centroid"
+	// VoxelModeFirst keeps the first point seen in each voxel as is
+	VoxelModeFirst VoxelMode = "first"
+	// VoxelModeColorAverage keeps one point per voxel at the centroid with the average color of its points
+	VoxelModeColorAverage VoxelMode = "color-average"
+)
+
+func ParseVoxelMode(s string) (VoxelMode, error) {
+	switch VoxelMode(s) {
+	case "":
+		return VoxelModeCentroid, nil
+	case VoxelModeCentroid, VoxelModeFirst, VoxelModeColorAverage:
+		return VoxelMode(s), nil
+	}
+	return "", fmt.Errorf("unknown voxel mode [%s]", s)
+}
+
+type voxelKey struct {
+	x, y, z int64
+}
+
+func newVoxelKey(p r3.Vector, size float64) voxelKey {
+	return voxelKey{
+		int64(math.Floor(p.X / size)),
+		int64(math.Floor(p.Y / size)),
+		int64(math.Floor(p.Z / size)),
+	}
+}
+
+type voxelAccumulator struct {
+	first      r3.Vector
+	data       pointcloud.Data
+	sum        r3.Vector
+	n          int
+	r, g, b    float64
+	numColored int
+}
+
+func (va *voxelAccumulator) add(p r3.Vector, d pointcloud.Data) {
+	if va.n == 0 {
+		va.first = p
+		va.data = d
+	}
+	va.sum = va.sum.Add(p)
+	va.n++
+
+	if d != nil && d.HasColor() {
+		r, g, b := d.RGB255()
+		va.r += float64(r)
+		va.g += float64(g)
+		va.b += float64(b)
+		va.numColored++
+	}
+}
+
+func (va *voxelAccumulator) centroid() r3.Vector {
+	return va.sum.Mul(1 / float64(va.n))
+}
+
+func (va *voxelAccumulator) averageData() pointcloud.Data {
+	if va.numColored == 0 {
+		return va.data
+	}
+	n := float64(va.numColored)
+	return pointcloud.NewColoredData(color.NRGBA{
+		R: uint8(math.Round(va.r / n)),
+		G: uint8(math.Round(va.g / n)),
+		B: uint8(math.Round(va.b / n)),
+		A: 255,
+	})
+}
+
+// PCVoxelDownsample reduces pc to at most one point per cube of side voxelSize (mm).
+func PCVoxelDownsample(pc pointcloud.PointCloud, voxelSize float64, mode VoxelMode) (pointcloud.PointCloud, error) {
+	if voxelSize <= 0 {
+		return nil, fmt.Errorf("voxelSize has to be positive, got %v", voxelSize)
+	}
+
+	if mode == "" {
+		mode = VoxelModeCentroid
+	}
+
+	voxels := map[voxelKey]*voxelAccumulator{}
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		k := newVoxelKey(p, voxelSize)
+		va, ok := voxels[k]
+		if !ok {
+			va = &voxelAccumulator{}
+			voxels[k] = va
+		}
+		va.add(p, d)
+		return true
+	})
+
+	out := pointcloud.NewBasicPointCloud(len(voxels))
+
+	for _, va := range voxels {
+		var err error
+		switch mode {
+		case VoxelModeCentroid:
+			err = out.Set(va.centroid(), va.data)
+		case VoxelModeFirst:
+			err = out.Set(va.first, va.data)
+		case VoxelModeColorAverage:
+			err = out.Set(va.centroid(), va.averageData())
+		default:
+			return nil, fmt.Errorf("unknown voxel mode [%s]", mode)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+type VoxelCameraConfig struct {
+	Src         string
+	VoxelSizeMM float64 `json:"voxel_size_mm"`
+	Mode        string
+
+	// Render controls how Image and Images draw the point cloud
+	Render *RenderOptions `json:"render,omitempty"`
+}
+
+func (c *VoxelCameraConfig) Validate(path string) ([]string, []string, error) {
+	if c.Src == "" {
+		return nil, nil, fmt.Errorf("need a src camera")
+	}
+	if c.Render != nil {
+		err := c.Render.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.VoxelSizeMM <= 0 {
+		return nil, nil, fmt.Errorf("need a positive voxel_size_mm")
+	}
+	_, err := ParseVoxelMode(c.Mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{c.Src}, nil, nil
+}
+
+func newVoxelCamera(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (camera.Camera, error) {
+	newConf, err := resource.NativeConfig[*VoxelCameraConfig](config)
+	if err != nil {
+		return nil, err
+	}
+
+	vc := &voxelCamera{
+		name:   config.ResourceName(),
+		cfg:    newConf,
+		logger: logger,
+	}
+
+	vc.mode, err = ParseVoxelMode(newConf.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	vc.src, err = camera.FromProvider(deps, newConf.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	return vc, nil
+}
+
+type voxelCamera struct {
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	name   resource.Name
+	cfg    *VoxelCameraConfig
+	logger logging.Logger
+
+	mode VoxelMode
+	src  camera.Camera
+}
+
+func (vc *voxelCamera) Name() resource.Name {
+	return vc.name
+}
+
+func (vc *voxelCamera) Image(ctx context.Context, mimeType string, extra map[string]interface{}) ([]byte, camera.ImageMetadata, error) {
+	pc, err := vc.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	img, err := PCToImageWithOptions(pc, vc.cfg.Render)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+
+	data, err := rimage.EncodeImage(ctx, img, mimeType)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+
+	return data, camera.ImageMetadata{MimeType: mimeType}, err
+}
+
+func (vc *voxelCamera) Images(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	pc, err := vc.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	img, err := PCToImageWithOptions(pc, vc.cfg.Render)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+
+	ni, err := camera.NamedImageFromImage(img, "voxel", "image/png", data.Annotations{})
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	return []camera.NamedImage{ni}, resource.ResponseMetadata{time.Now()}, nil
+}
+
+func (vc *voxelCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (vc *voxelCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	pc, err := vc.src.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	out, err := PCVoxelDownsample(pc, vc.cfg.VoxelSizeMM, vc.mode)
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := time.Since(start)
+	if elapsed > (time.Millisecond * 100) {
+		vc.logger.Infof("PCVoxelDownsample took %v (%d -> %d points)", elapsed, pc.Size(), out.Size())
+	}
+
+	return out, nil
+}
+
+func (vc *voxelCamera) Properties(ctx context.Context) (camera.Properties, error) {
+	return camera.Properties{
+		SupportsPCD: true,
+	}, nil
+}
+
+func (vc *voxelCamera) Geometries(ctx context.Context, _ map[string]interface{}) ([]spatialmath.Geometry, error) {
+	return nil, nil
+}
@@ -0,0 +1,60 @@
+package touch
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+)
+
+func TestPCVoxelDownsample(t *testing.T) {
+	in := pointcloud.NewBasicEmpty()
+	test.That(t, in.Set(r3.Vector{1, 1, 1}, pointcloud.NewColoredData(color.NRGBA{100, 0, 0, 255})), test.ShouldBeNil)
+	test.That(t, in.Set(r3.Vector{3, 3, 3}, pointcloud.NewColoredData(color.NRGBA{200, 0, 0, 255})), test.ShouldBeNil)
+	test.That(t, in.Set(r3.Vector{15, 1, 1}, pointcloud.NewColoredData(color.NRGBA{0, 0, 50, 255})), test.ShouldBeNil)
+
+	out, err := PCVoxelDownsample(in, 10, VoxelModeCentroid)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 2)
+	test.That(t, pointcloud.CloudContains(out, 2, 2, 2), test.ShouldBeTrue)
+	test.That(t, pointcloud.CloudContains(out, 15, 1, 1), test.ShouldBeTrue)
+
+	out, err = PCVoxelDownsample(in, 10, VoxelModeFirst)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 2)
+	test.That(t, pointcloud.CloudContains(out, 2, 2, 2), test.ShouldBeFalse)
+
+	out, err = PCVoxelDownsample(in, 10, VoxelModeColorAverage)
+	test.That(t, err, test.ShouldBeNil)
+	d, ok := out.At(2, 2, 2)
+	test.That(t, ok, test.ShouldBeTrue)
+	r, _, _ := d.RGB255()
+	test.That(t, r, test.ShouldEqual, 150)
+
+	_, err = PCVoxelDownsample(in, 0, VoxelModeCentroid)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPCVoxelDownsampleFile(t *testing.T) {
+	in, err := pointcloud.NewFromFile("data/cup1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	out, err := PCVoxelDownsample(in, 5, VoxelModeCentroid)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldBeGreaterThan, 0)
+	test.That(t, out.Size(), test.ShouldBeLessThan, in.Size())
+}
+
+func BenchmarkPCVoxelDownsample(t *testing.B) {
+	in, err := pointcloud.NewFromFile("data/cup1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	t.ResetTimer()
+	for range t.N {
+		_, err := PCVoxelDownsample(in, 5, VoxelModeCentroid)
+		test.That(t, err, test.ShouldBeNil)
+	}
+}
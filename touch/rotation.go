@@ -0,0 +1,87 @@
+package touch
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// mat3 is a row major 3x3 rotation matrix that rotates the same way spatialmath.Compose does.
+// spatialmath.RotationMatrix stores the transpose of that, which is easy to get wrong.
+type mat3 [9]float64
+
+func identityMat3() mat3 {
+	return mat3{1, 0, 0, 0, 1, 0, 0, 0, 1}
+}
+
+func mat3FromOrientation(o spatialmath.Orientation) mat3 {
+	rm := o.RotationMatrix()
+	m := mat3{}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			m[3*r+c] = rm.At(c, r)
+		}
+	}
+	return m
+}
+
+// mat3FromCols is the matrix with columns a, b, c.
+func mat3FromCols(a, b, c r3.Vector) mat3 {
+	return mat3{a.X, b.X, c.X, a.Y, b.Y, c.Y, a.Z, b.Z, c.Z}
+}
+
+// rodrigues is the rotation of |v| radians around v.
+func rodrigues(v r3.Vector) mat3 {
+	theta := v.Norm()
+	if theta < 1e-12 {
+		return identityMat3()
+	}
+	k := v.Mul(1 / theta)
+	s, c := math.Sin(theta), math.Cos(theta)
+	t := 1 - c
+	return mat3{
+		c + k.X*k.X*t, k.X*k.Y*t - k.Z*s, k.X*k.Z*t + k.Y*s,
+		k.Y*k.X*t + k.Z*s, c + k.Y*k.Y*t, k.Y*k.Z*t - k.X*s,
+		k.Z*k.X*t - k.Y*s, k.Z*k.Y*t + k.X*s, c + k.Z*k.Z*t,
+	}
+}
+
+func (m mat3) mulVec(v r3.Vector) r3.Vector {
+	return r3.Vector{
+		X: m[0]*v.X + m[1]*v.Y + m[2]*v.Z,
+		Y: m[3]*v.X + m[4]*v.Y + m[5]*v.Z,
+		Z: m[6]*v.X + m[7]*v.Y + m[8]*v.Z,
+	}
+}
+
+func (m mat3) mul(o mat3) mat3 {
+	res := mat3{}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			for k := 0; k < 3; k++ {
+				res[3*r+c] += m[3*r+k] * o[3*k+c]
+			}
+		}
+	}
+	return res
+}
+
+func (m mat3) transpose() mat3 {
+	return mat3{m[0], m[3], m[6], m[1], m[4], m[7], m[2], m[5], m[8]}
+}
+
+func (m mat3) orientation() spatialmath.Orientation {
+	t := m.transpose()
+	rm, err := spatialmath.NewRotationMatrix(t[:])
+	if err != nil {
+		// can't happen, it's always 9 long
+		panic(err)
+	}
+	return rm
+}
+
+func (m mat3) pose(t r3.Vector) spatialmath.Pose {
+	return spatialmath.NewPose(t, m.orientation())
+}
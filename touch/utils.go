@@ -2,9 +2,9 @@ package touch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
-	"image/color"
 	"math"
 	"strconv"
 	"time"
@@ -22,6 +22,7 @@ import (
 	"go.viam.com/rdk/services/motion"
 	"go.viam.com/rdk/services/vision"
 	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
 	"go.viam.com/utils/trace"
 
 	"github.com/erh/vmodutils/file_utils"
@@ -111,26 +112,6 @@ func PCCrop(pc pointcloud.PointCloud, min, max r3.Vector) pointcloud.PointCloud
 	return PCCropWithColor(pc, min, max, nil)
 }
 
-type ColorFilter struct {
-	Color    color.RGBA
-	Distance float64
-}
-
-func EuclideanRGB(c1, c2 color.Color) float64 {
-	r1, g1, b1, _ := c1.RGBA()
-	r2, g2, b2, _ := c2.RGBA()
-
-	// RGBA() returns uint32 in range [0, 65535], convert to [0, 255]
-	r1, g1, b1 = r1>>8, g1>>8, b1>>8
-	r2, g2, b2 = r2>>8, g2>>8, b2>>8
-
-	dr := int(r1) - int(r2)
-	dg := int(g1) - int(g2)
-	db := int(b1) - int(b2)
-
-	return math.Sqrt(float64(dr*dr + dg*dg + db*db))
-}
-
 func PCCropWithColor(pc pointcloud.PointCloud, min, max r3.Vector, colorFilters []ColorFilter) pointcloud.PointCloud {
 
 	fixed := pointcloud.NewBasicEmpty()
@@ -140,11 +121,8 @@ func PCCropWithColor(pc pointcloud.PointCloud, min, max r3.Vector, colorFilters
 			return true
 		}
 
-		for _, cf := range colorFilters {
-			dis := EuclideanRGB(cf.Color, d.Color())
-			if dis > cf.Distance {
-				return true
-			}
+		if !ColorFiltersMatch(colorFilters, d.Color()) {
+			return true
 		}
 
 		fixed.Set(p, d)
@@ -154,66 +132,73 @@ func PCCropWithColor(pc pointcloud.PointCloud, min, max r3.Vector, colorFilters
 	return fixed
 }
 
-func PCToImage(pc pointcloud.PointCloud) image.Image {
-
-	md := pc.MetaData()
-
-	r := image.Rect(
-		int(math.Floor(md.MinX)),
-		int(math.Floor(md.MinY)),
-		int(math.Ceil(md.MaxX)),
-		int(math.Ceil(md.MaxY)),
-	)
-
-	xScale := 0
-	yScale := 0
-
-	if r.Min.X < 0 {
-		xScale = -1 * r.Min.X
-		r.Min.X += xScale
-		r.Max.X += xScale
-	}
-
-	if r.Min.Y < 0 {
-		yScale = -1 * r.Min.Y
-		r.Min.Y += yScale
-		r.Max.Y += yScale
-	}
-
-	if r.Max.X <= 0 {
-		r.Max.X = 1
-	}
-
-	if r.Max.Y <= 0 {
-		r.Max.Y = 1
+// PCCropWithVolumes keeps points that are in any of include (or all points if include is empty),
+// are not in any of exclude, and match colorFilters.
+func PCCropWithVolumes(
+	pc pointcloud.PointCloud,
+	include, exclude []spatialmath.Geometry,
+	colorFilters []ColorFilter,
+) (pointcloud.PointCloud, error) {
+	inAny := func(p r3.Vector, geometries []spatialmath.Geometry) (bool, error) {
+		pt := spatialmath.NewPoint(p, "")
+		for _, g := range geometries {
+			// a tiny buffer, inside points can be a rounding error away from their closest point on rotated geometries
+			in, _, err := g.CollidesWith(pt, 1e-6)
+			if err != nil {
+				return false, err
+			}
+			if in {
+				return true, nil
+			}
+		}
+		return false, nil
 	}
 
-	img := image.NewRGBA(r)
+	fixed := pointcloud.NewBasicEmpty()
 
-	bestZ := make([]float64, (1+r.Max.X)*(1+r.Max.Y)) //map[int]float64{}
+	var err error
 	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
-		x := int(p.X) + xScale
-		y := int(p.Y) + yScale
-
-		key := (y * r.Max.X) + x
-		oldZ := bestZ[key]
-		ok := oldZ != 0
-		if ok {
-			if p.Z < oldZ {
+		if len(include) > 0 {
+			var in bool
+			in, err = inAny(p, include)
+			if err != nil {
+				return false
+			}
+			if !in {
 				return true
 			}
 		}
 
-		img.Set(x, y, d.Color())
+		var out bool
+		out, err = inAny(p, exclude)
+		if err != nil {
+			return false
+		}
+		if out {
+			return true
+		}
 
-		if p.Z == 0 {
-			p.Z = .0001
+		if !ColorFiltersMatch(colorFilters, d.Color()) {
+			return true
 		}
-		bestZ[key] = p.Z
 
-		return true
+		err = fixed.Set(p, d)
+		return err == nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fixed, nil
+}
 
+// PCToImage renders pc from the top at 1 pixel per mm, see PCToImageWithOptions.
+func PCToImage(pc pointcloud.PointCloud) image.Image {
+	img, err := PCToImageWithOptions(pc, nil)
+	if err != nil {
+		// the default options are always valid
+		panic(err)
+	}
 	return img
 }
 
@@ -270,10 +255,120 @@ func writeFilesForPosition(ctx context.Context, traceID string, pos int, pc poin
 	return nil
 }
 
+// ArmRestore moves an arm back to the joints it had before a scan. If Motion is set it goes through
+// motion planning around the VisionServices' obstacles, otherwise straight there with MoveToJointPositions.
+type ArmRestore struct {
+	Arm            arm.Arm
+	Motion         motion.Service
+	VisionServices []vision.Service
+	Extra          map[string]any
+	Logger         logging.Logger
+}
+
+// restoreTimeout is how long the arm gets to go back once the scan's context is done.
+const restoreTimeout = 2 * time.Minute
+
+// record saves where the arm is now and returns a func that moves it back.
+// Moving back ignores ctx being cancelled so an aborted scan still puts the arm back.
+func (ar *ArmRestore) record(ctx context.Context) (func() error, error) {
+	armName := ar.Arm.Name().Name
+
+	joints, err := ar.Arm.JointPositions(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get starting joints of %s: %w", armName, err)
+	}
+
+	logger := ar.Logger
+	if logger == nil {
+		logger = logging.NewBlankLogger("arm-restore")
+	}
+
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+		defer cancel()
+
+		logger.Debugf("moving %s back to %v", armName, joints)
+
+		var err error
+		if ar.Motion != nil {
+			err = goToPositionUsingJointToJointMotion(ctx, joints, armName, ar.Motion, ar.VisionServices, ar.Extra, logger)
+		} else {
+			err = goToPositionUsingMoveToJointPositions(ctx, joints, ar.Arm, ar.Extra, logger)
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't move %s back to where it started: %w", armName, err)
+		}
+		return nil
+	}, nil
+}
+
+// GetMergedPointCloudFromPositions moves through positions, merging the src camera's clouds in the world frame.
 func GetMergedPointCloudFromPositions(ctx context.Context, positions []toggleswitch.Switch, sleepTime time.Duration, srcCamera camera.Camera, extraForCamera map[string]any, fsSvc framesystem.Service, writeFilesToCaptureDirectory bool) (pointcloud.PointCloud, error) {
+	return GetMergedPointCloudFromPositionsWithRestore(ctx, positions, sleepTime, srcCamera, extraForCamera, fsSvc, writeFilesToCaptureDirectory, nil)
+}
+
+// GetMergedPointCloudFromPositionsWithRestore is GetMergedPointCloudFromPositions, and if restore is not nil
+// its arm is put back where it started afterwards, even if the scan fails.
+func GetMergedPointCloudFromPositionsWithRestore(ctx context.Context, positions []toggleswitch.Switch, sleepTime time.Duration, srcCamera camera.Camera, extraForCamera map[string]any, fsSvc framesystem.Service, writeFilesToCaptureDirectory bool, restore *ArmRestore) (pointcloud.PointCloud, error) {
+	scan, err := scanPositions(ctx, positions, sleepTime, srcCamera, extraForCamera, fsSvc, scanOptions{
+		writeFilesToCaptureDirectory: writeFilesToCaptureDirectory,
+		restore:                      restore,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scan.cloud, nil
+}
+
+// positionsScan is everything we got from one pass through a set of arm positions.
+type positionsScan struct {
+	// cloud is the merged cloud in the world frame
+	cloud pointcloud.PointCloud
+	// images are the src camera's color image from each position, named pose-0, pose-1, ...
+	images []camera.NamedImage
+	// poseSizes is how many points came from each position
+	poseSizes []int
+	// icpFitness is RegisterICP's fitness for each position after the first, if refining
+	icpFitness []float64
+
+	start    time.Time
+	duration time.Duration
+}
+
+// age is how long ago the scan finished.
+func (s *positionsScan) age() time.Duration {
+	return time.Since(s.start.Add(s.duration))
+}
+
+type scanOptions struct {
+	writeFilesToCaptureDirectory bool
+	// poseImages keeps the src camera's color image from each position
+	poseImages bool
+	// restore, if set, puts its arm back afterwards
+	restore *ArmRestore
+	// icp, if set, lines each position's cloud up with the ones before it
+	icp *ICPOptions
+}
+
+// scanPositions is GetMergedPointCloudFromPositions with more options.
+func scanPositions(ctx context.Context, positions []toggleswitch.Switch, sleepTime time.Duration, srcCamera camera.Camera, extraForCamera map[string]any, fsSvc framesystem.Service, opts scanOptions) (_ *positionsScan, err error) {
+	if opts.restore != nil {
+		moveBack, recordErr := opts.restore.record(ctx)
+		if recordErr != nil {
+			return nil, recordErr
+		}
+		defer func() {
+			err = errors.Join(err, moveBack())
+		}()
+	}
+
+	scan := &positionsScan{start: time.Now()}
 	pcsInWorld := []pointcloud.PointCloud{}
 	totalSize := 0
 
+	// everything so far, for icp to line up with
+	accumulated := pointcloud.NewBasicEmpty()
+
 	// If a traceID is present, we will write files to a traceID sub-directory in the capture directory.
 	// Otherwise, we will write files at the top-level of the capture directory.
 	var traceID string
@@ -282,7 +377,8 @@ func GetMergedPointCloudFromPositions(ctx context.Context, positions []toggleswi
 	}
 
 	for i, p := range positions {
-		err := p.SetPosition(ctx, 2, nil)
+		// even async switches have to be there before the camera looks
+		err := p.SetPosition(ctx, 2, waitExtra())
 		if err != nil {
 			return nil, err
 		}
@@ -296,27 +392,54 @@ func GetMergedPointCloudFromPositions(ctx context.Context, positions []toggleswi
 		}
 
 		totalSize += pc.Size()
+		scan.poseSizes = append(scan.poseSizes, pc.Size())
 
 		// Transform this point cloud into the world frame
 		pif, err := fsSvc.GetPose(ctx, srcCamera.Name().Name, "", nil, nil)
 		if err != nil {
 			return nil, err
 		}
-		pcInWorld := pointcloud.NewBasicPointCloud(pc.Size())
+		var pcInWorld pointcloud.PointCloud = pointcloud.NewBasicPointCloud(pc.Size())
 		err = pointcloud.ApplyOffset(pc, pif.Pose(), pcInWorld)
 		if err != nil {
 			return nil, err
 		}
 
+		if opts.icp != nil {
+			if i > 0 {
+				// if it doesn't line up we keep the frame system pose, the fitness says how it went
+				refined, fitness, err := refineWithICP(pcInWorld, accumulated, opts.icp)
+				if err == nil {
+					pcInWorld = refined
+				}
+				scan.icpFitness = append(scan.icpFitness, fitness)
+			}
+			err = pointcloud.ApplyOffset(pcInWorld, nil, accumulated)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		pcsInWorld = append(pcsInWorld, pcInWorld)
 
-		if writeFilesToCaptureDirectory {
+		if opts.writeFilesToCaptureDirectory || opts.poseImages {
 			images, imagesMd, err := srcCamera.Images(ctx, nil, nil)
 			if err != nil {
 				return nil, fmt.Errorf("couldn't get images from camera: %w", err)
 			}
-			if err := writeFilesForPosition(ctx, traceID, i, pc, pif, pcInWorld, images, imagesMd); err != nil {
-				return nil, err
+			if opts.writeFilesToCaptureDirectory {
+				if err := writeFilesForPosition(ctx, traceID, i, pc, pif, pcInWorld, images, imagesMd); err != nil {
+					return nil, err
+				}
+			}
+			if opts.poseImages {
+				ni, ok, err := poseImage(ctx, images, i)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					scan.images = append(scan.images, ni)
+				}
 			}
 		}
 	}
@@ -331,7 +454,7 @@ func GetMergedPointCloudFromPositions(ctx context.Context, positions []toggleswi
 		}
 	}
 
-	if writeFilesToCaptureDirectory {
+	if opts.writeFilesToCaptureDirectory {
 		// Save merged pcd
 		dirPath := file_utils.GetPathInCaptureDir(traceID)
 		if err := file_utils.SavePointCloudFile(big, dirPath, "merged.pcd", time.Now()); err != nil {
@@ -339,7 +462,29 @@ func GetMergedPointCloudFromPositions(ctx context.Context, positions []toggleswi
 		}
 	}
 
-	return big, nil
+	scan.cloud = big
+	scan.duration = time.Since(scan.start)
+
+	return scan, nil
+}
+
+// poseImage is the first non depth image from images renamed pose-<pos>, false if there isn't one.
+func poseImage(ctx context.Context, images []camera.NamedImage, pos int) (camera.NamedImage, bool, error) {
+	for _, im := range images {
+		if im.MimeType() == utils.MimeTypeRawDepth {
+			continue
+		}
+		raw, err := im.Bytes(ctx)
+		if err != nil {
+			return camera.NamedImage{}, false, err
+		}
+		ni, err := camera.NamedImageFromBytes(raw, "pose-"+strconv.Itoa(pos), im.MimeType(), im.Annotations())
+		if err != nil {
+			return camera.NamedImage{}, false, err
+		}
+		return ni, true, nil
+	}
+	return camera.NamedImage{}, false, nil
 }
 
 func buildWorldStateWithObstacles(ctx context.Context, visionSvcs []vision.Service) (*referenceframe.WorldState, error) {
@@ -379,18 +524,21 @@ func goToPositionUsingJointToJointMotion(
 	// Express the goal state in joint positions
 	goalFrameSystemInputs := make(referenceframe.FrameSystemInputs)
 	goalFrameSystemInputs[armName] = joints
-	if extra == nil {
-		extra = make(map[string]any)
-	} else if extra[extraParamsKeyGoalState] != nil {
+	if extra[extraParamsKeyGoalState] != nil {
 		return fmt.Errorf("cannot provide '%s' in 'extra' when using joint to joint motion", extraParamsKeyGoalState)
 	}
-	extra[extraParamsKeyGoalState] = serialize(goalFrameSystemInputs)
+	// copy so the caller's extra can be used again for the next move
+	moveExtra := make(map[string]any, len(extra)+1)
+	for k, v := range extra {
+		moveExtra[k] = v
+	}
+	moveExtra[extraParamsKeyGoalState] = serialize(goalFrameSystemInputs)
 
 	// Call Motion.Move
 	_, err = motionSvc.Move(ctx, motion.MoveReq{
 		ComponentName: armName,
 		WorldState:    worldState,
-		Extra:         extra,
+		Extra:         moveExtra,
 	})
 	return err
 }
@@ -406,10 +554,70 @@ func goToPositionUsingMoveToJointPositions(
 	return arm.MoveToJointPositions(ctx, joints, extra)
 }
 
+// ArmPositionUnknownLabel is the last label of the arm switches, the position they're at when the arm
+// isn't at any of the real ones. It can't be set.
+const ArmPositionUnknownLabel = "unknown"
+
+// settablePositions is how many positions of s can be set, which leaves out the unknown one at the end.
+func settablePositions(ctx context.Context, s toggleswitch.Switch) (uint32, error) {
+	n, labels, err := s.GetNumberOfPositions(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 && len(labels) == int(n) && labels[n-1] == ArmPositionUnknownLabel {
+		n--
+	}
+	return n, nil
+}
+
+// armTolerances is how close the arm has to be to a position to be at it.
+type armTolerances struct {
+	jointRads       float64
+	mm              float64
+	orientationRads float64
+}
+
+// newArmTolerances uses 1 for anything not set.
+func newArmTolerances(jointDegs, mm, orientationDegs float64) armTolerances {
+	if jointDegs <= 0 {
+		jointDegs = 1
+	}
+	if mm <= 0 {
+		mm = 1
+	}
+	if orientationDegs <= 0 {
+		orientationDegs = 1
+	}
+	return armTolerances{
+		jointRads:       utils.DegToRad(jointDegs),
+		mm:              mm,
+		orientationRads: utils.DegToRad(orientationDegs),
+	}
+}
+
+func (t armTolerances) jointsMatch(current, target []referenceframe.Input) bool {
+	if len(current) != len(target) {
+		return false
+	}
+	for i := range current {
+		if math.Abs(current[i]-target[i]) > t.jointRads {
+			return false
+		}
+	}
+	return true
+}
+
+func (t armTolerances) poseMatches(current, target spatialmath.Pose) bool {
+	if current.Point().Distance(target.Point()) > t.mm {
+		return false
+	}
+	return spatialmath.QuatToR3AA(spatialmath.OrientationBetween(current.Orientation(), target.Orientation()).Quaternion()).Norm() <= t.orientationRads
+}
+
+// goToPositionUsingCartesianMotion moves the arm to goal, which can be in any frame, with motion planning.
 func goToPositionUsingCartesianMotion(
 	ctx context.Context,
-	point r3.Vector,
-	orientation spatialmath.OrientationVectorDegrees,
+	goal *referenceframe.PoseInFrame,
 	motionSvc motion.Service,
 	visionSvcs []vision.Service,
 	fsSvc framesystem.Service,
@@ -420,13 +628,13 @@ func goToPositionUsingCartesianMotion(
 	logger.Debugf("going to position using cartesian motion")
 
 	// Check if we are already close enough
-	current, err := fsSvc.GetPose(ctx, armName, referenceframe.World, nil, nil)
+	current, err := fsSvc.GetPose(ctx, armName, goal.Parent(), nil, nil)
 	if err != nil {
 		return err
 	}
 
-	linearDelta := current.Pose().Point().Distance(point)
-	orientationDelta := spatialmath.QuatToR3AA(spatialmath.OrientationBetween(current.Pose().Orientation(), &orientation).Quaternion()).Norm2()
+	linearDelta := current.Pose().Point().Distance(goal.Pose().Point())
+	orientationDelta := spatialmath.QuatToR3AA(spatialmath.OrientationBetween(current.Pose().Orientation(), goal.Pose().Orientation()).Quaternion()).Norm2()
 
 	logger.Debugf("goToSavePosition linearDelta: %v orientationDelta: %v", linearDelta, orientationDelta)
 	if linearDelta < .1 && orientationDelta < .01 {
@@ -440,18 +648,12 @@ func goToPositionUsingCartesianMotion(
 		return err
 	}
 
-	// Express the goal state in cartesian pose
-	pif := referenceframe.NewPoseInFrame(
-		referenceframe.World,
-		spatialmath.NewPose(point, &orientation),
-	)
-
 	// Call Motion.Move
 	done, err := motionSvc.Move(
 		ctx,
 		motion.MoveReq{
 			ComponentName: armName,
-			Destination:   pif,
+			Destination:   goal,
 			WorldState:    worldState,
 			Extra:         extra,
 		},
@@ -475,7 +677,24 @@ func serialize(inputs referenceframe.FrameSystemInputs) map[string]any {
 	return m
 }
 
+// GetMergedPointCloudFromMultiPositionSwitch goes to every position of s, merging the src camera's clouds in the world frame.
 func GetMergedPointCloudFromMultiPositionSwitch(ctx context.Context, s toggleswitch.Switch, sleepTime time.Duration, srcCamera camera.Camera, extraForCamera map[string]any, fsSvc framesystem.Service, writeFilesToCaptureDirectory bool) (pointcloud.PointCloud, error) {
+	return GetMergedPointCloudFromMultiPositionSwitchWithRestore(ctx, s, sleepTime, srcCamera, extraForCamera, fsSvc, writeFilesToCaptureDirectory, nil)
+}
+
+// GetMergedPointCloudFromMultiPositionSwitchWithRestore is GetMergedPointCloudFromMultiPositionSwitch, and if restore is not nil
+// its arm is put back where it started afterwards, even if the scan fails.
+func GetMergedPointCloudFromMultiPositionSwitchWithRestore(ctx context.Context, s toggleswitch.Switch, sleepTime time.Duration, srcCamera camera.Camera, extraForCamera map[string]any, fsSvc framesystem.Service, writeFilesToCaptureDirectory bool, restore *ArmRestore) (_ pointcloud.PointCloud, err error) {
+	if restore != nil {
+		moveBack, recordErr := restore.record(ctx)
+		if recordErr != nil {
+			return nil, recordErr
+		}
+		defer func() {
+			err = errors.Join(err, moveBack())
+		}()
+	}
+
 	pcsInWorld := []pointcloud.PointCloud{}
 	totalSize := 0
 
@@ -486,12 +705,12 @@ func GetMergedPointCloudFromMultiPositionSwitch(ctx context.Context, s toggleswi
 		traceID = span.SpanContext().TraceID().String()
 	}
 
-	numPositions, _, err := s.GetNumberOfPositions(ctx, nil)
+	numPositions, err := settablePositions(ctx, s)
 	if err != nil {
 		return nil, err
 	}
 	for i := range numPositions {
-		err := s.SetPosition(ctx, i, nil)
+		err := s.SetPosition(ctx, i, waitExtra())
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,8 @@
 package touch
 
 import (
+	"context"
+	"encoding/json"
 	"image"
 	"image/color"
 	"image/png"
@@ -10,10 +12,13 @@ import (
 
 	"github.com/golang/geo/r3"
 
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/rdk/rimage"
 	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
 	"go.viam.com/test"
 )
 
@@ -200,9 +205,68 @@ func TestPCCropColor(t *testing.T) {
 	test.That(t, filtered.Size(), test.ShouldEqual, in.Size())
 
 	filtered = PCCropWithColor(in, min, max, []ColorFilter{
-		{color.RGBA{0, 0, 0, 0}, 100},
+		{Color: color.RGBA{0, 0, 0, 0}, Distance: 100},
 	})
 
 	test.That(t, filtered.Size(), test.ShouldBeLessThan, in.Size())
 
 }
+
+func TestPCCropWithVolumes(t *testing.T) {
+	in := pointcloud.NewBasicEmpty()
+	for x := -100; x <= 100; x += 10 {
+		for y := -100; y <= 100; y += 10 {
+			test.That(t, in.Set(r3.Vector{float64(x), float64(y), 0}, pointcloud.NewBasicData()), test.ShouldBeNil)
+		}
+	}
+
+	// a 100x10 box rotated 45 degrees, only the diagonal is in it
+	cfg := spatialmath.GeometryConfig{}
+	err := json.Unmarshal([]byte(`{
+		"type" : "box", "x" : 100, "y" : 10, "z" : 10,
+		"orientation" : { "type" : "ov_degrees", "value" : { "x" : 0, "y" : 0, "z" : 1, "th" : 45 } }
+	}`), &cfg)
+	test.That(t, err, test.ShouldBeNil)
+	box, err := cfg.ParseConfig()
+	test.That(t, err, test.ShouldBeNil)
+
+	out, err := PCCropWithVolumes(in, []spatialmath.Geometry{box}, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 7)
+	out.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		test.That(t, p.X, test.ShouldEqual, p.Y)
+		return true
+	})
+
+	// take out the middle
+	sphere, err := spatialmath.NewSphere(spatialmath.NewZeroPose(), 5, "")
+	test.That(t, err, test.ShouldBeNil)
+
+	out, err = PCCropWithVolumes(in, []spatialmath.Geometry{box}, []spatialmath.Geometry{sphere}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 6)
+
+	// no include means everything but the excluded
+	out, err = PCCropWithVolumes(in, nil, []spatialmath.Geometry{sphere}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, in.Size()-1)
+}
+
+func TestPoseImage(t *testing.T) {
+	ctx := context.Background()
+
+	depth, err := camera.NamedImageFromBytes([]byte{1}, "depth", utils.MimeTypeRawDepth, data.Annotations{})
+	test.That(t, err, test.ShouldBeNil)
+	rgb, err := camera.NamedImageFromBytes([]byte{2}, "color", utils.MimeTypeJPEG, data.Annotations{})
+	test.That(t, err, test.ShouldBeNil)
+
+	ni, ok, err := poseImage(ctx, []camera.NamedImage{depth, rgb}, 3)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, ni.SourceName, test.ShouldEqual, "pose-3")
+	test.That(t, ni.MimeType(), test.ShouldEqual, utils.MimeTypeJPEG)
+
+	_, ok, err = poseImage(ctx, []camera.NamedImage{depth}, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ok, test.ShouldBeFalse)
+}